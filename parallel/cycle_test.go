@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCycle tests that the first world to repeat an earlier one is reported with its period, against a stepper
+// of its own that keeps every world, and that -stop-on-cycle finishes the run there with its image output.
+func TestCycle(t *testing.T) {
+	for _, test := range []struct {
+		size int
+		rule string
+	}{
+		{16, "B3/S23"},
+		{64, "B3/S23"},
+		{16, "345/2/4"},
+	} {
+		rule, err := util.ParseRule(test.rule)
+		util.Check(err)
+		world := util.MakeWorld(test.size, test.size)
+		for _, cell := range readAliveCells(fmt.Sprintf("check/images/%vx%vx0.pgm", test.size, test.size), test.size, test.size) {
+			world[cell.Y][cell.X] = 255
+		}
+		seen := map[string]int{fmt.Sprint(world): 0}
+		firstSeen, turn := 0, 0
+		for found := false; !found; {
+			world = stepTorus(rule, world)
+			turn++
+			firstSeen, found = seen[fmt.Sprint(world)]
+			seen[fmt.Sprint(world)] = turn
+		}
+		expectedAlive := worldAliveCells(world)
+		expectedCycle := gol.CycleDetected{CompletedTurns: turn, Period: turn - firstSeen, FirstSeenTurn: firstSeen}
+
+		for _, stop := range []bool{true, false} {
+			p := gol.Params{ImageWidth: test.size, ImageHeight: test.size, Turns: turn + 10, Threads: 4, Rule: test.rule, StopOnCycle: stop}
+			t.Run(fmt.Sprintf("%dx%d-%v-%v", p.ImageWidth, p.ImageHeight, p.Rule, stop), func(t *testing.T) {
+				run := runEvents(p)
+				if len(run.cycles) != 1 || run.cycles[0] != expectedCycle {
+					t.Errorf("ERROR: Expected %v once, got %v", expectedCycle, run.cycles)
+				}
+				if !stop {
+					if run.final.CompletedTurns != p.Turns || run.final.Reason != gol.TurnsCompleted {
+						t.Errorf("ERROR: Expected the run to go on to turn %v, got %v (%v)", p.Turns, run.final.CompletedTurns, run.final.Reason)
+					}
+					return
+				}
+				if run.final.CompletedTurns != turn || run.final.Reason != gol.CycleFound {
+					t.Errorf("ERROR: Expected the run to finish in turn %v as %v, got %v (%v)", turn, gol.CycleFound, run.final.CompletedTurns, run.final.Reason)
+				}
+				assertEqualBoard(t, run.final.Alive, expectedAlive, p)
+				if expectedName := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn); fmt.Sprint(run.filenames) != fmt.Sprint([]string{expectedName}) {
+					t.Errorf("ERROR: Expected the final image in %v, got %v", expectedName, run.filenames)
+				}
+			})
+		}
+	}
+}
+
+// TestCycleLimits tests that a world that comes back is only a cycle when the run really repeats from there:
+// block rules need the same partition, so the turns must have the same parity, and stochastic rules never repeat.
+func TestCycleLimits(t *testing.T) {
+	// the first partition leaves the glider of the 16x16 image as it is, and the second one moves it on
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 200, Threads: 1,
+		Rule: "MS,D0;2;1;3;4;5;6;7;8;9;10;11;12;13;14;15", StopOnCycle: true}
+	t.Run("margolus", func(t *testing.T) {
+		run := runEvents(p)
+		if len(run.cycles) != 1 || run.final.CompletedTurns != run.cycles[0].CompletedTurns {
+			t.Fatalf("ERROR: Expected one cycle to finish the run, got %v and turn %v", run.cycles, run.final.CompletedTurns)
+		}
+		// the run goes on from the turn of the cycle as it did from the turn the world was first seen in
+		cycle := run.cycles[0]
+		p.StopOnCycle = false
+		for k := 0; k <= 2*cycle.Period; k++ {
+			p.Turns = cycle.FirstSeenTurn + k
+			first := finalAlive(p)
+			p.Turns = cycle.CompletedTurns + k
+			again := finalAlive(p)
+			if !assertEqualBoard(t, again, first, p) {
+				t.Fatalf("ERROR: %v does not repeat %v turns on", cycle, k)
+			}
+		}
+	})
+
+	for _, seed := range []uint64{1, 2} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 1, Rule: "B3(0.5)/S23", Seed: seed, StopOnCycle: true}
+		t.Run(fmt.Sprintf("stochastic-%v", seed), func(t *testing.T) {
+			run := runEvents(p)
+			if len(run.cycles) != 0 || run.final.CompletedTurns != p.Turns {
+				t.Errorf("ERROR: Expected no cycles and the run to reach turn %v, got %v and turn %v",
+					p.Turns, run.cycles, run.final.CompletedTurns)
+			}
+		})
+	}
+}
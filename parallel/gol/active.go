@@ -0,0 +1,79 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Tiles are only stepped when something near them changed. Tiles of 64 columns line up with the words
+// of a util.BitWorld.
+const (
+	tileWidth  = 64
+	tileHeight = 8
+)
+
+// activeTiles records which tiles of the world are stepped in the next turn. The next state of a cell only
+// depends on the cells within the range of the rule, so a cell can only change if one of those changed
+// in the last turn. Every other cell keeps its value, which the back buffer of a workerPool already holds.
+// A nil *activeTiles makes every tile active.
+type activeTiles struct {
+	width, height int // of the world, in cells
+	columns, rows int // of the world, in tiles
+	active        []bool
+}
+
+// newActiveTiles tracks the tiles of a width x height world, all of which start active.
+func newActiveTiles(width, height int) *activeTiles {
+	a := &activeTiles{
+		width:   width,
+		height:  height,
+		columns: (width + tileWidth - 1) / tileWidth,
+		rows:    (height + tileHeight - 1) / tileHeight,
+	}
+	a.active = make([]bool, a.columns*a.rows)
+	for i := range a.active {
+		a.active[i] = true
+	}
+	return a
+}
+
+// isActive reports whether the tile of cell (x, y) is stepped.
+func (a *activeTiles) isActive(x, y int) bool {
+	return a == nil || a.active[(y/tileHeight)*a.columns+x/tileWidth]
+}
+
+// track makes active the tiles within r cells of the cells that changed in the last turn.
+// Neighbourhoods that cross a joined edge can reach anywhere along the edges, so a change within r cells
+// of an edge makes every tile along the edges active.
+func (a *activeTiles) track(cells []util.Cell, r int, topology util.Topology) {
+	for i := range a.active {
+		a.active[i] = false
+	}
+
+	nearEdge := false
+	for _, cell := range cells {
+		if cell.X < r || cell.Y < r || cell.X >= a.width-r || cell.Y >= a.height-r {
+			nearEdge = true
+		}
+		// tiles are wider and taller than r, so every tile the neighbourhood touches holds one of these cells.
+		// The last tiles of a row or column can be narrower, but they hold the edge the cells are moved onto.
+		for _, dy := range [3]int{-r, 0, r} {
+			for _, dx := range [3]int{-r, 0, r} {
+				x := minInt(maxInt(cell.X+dx, 0), a.width-1)
+				y := minInt(maxInt(cell.Y+dy, 0), a.height-1)
+				a.active[(y/tileHeight)*a.columns+x/tileWidth] = true
+			}
+		}
+	}
+
+	joined := topology != util.Plane && topology != util.AliveEdges && topology != util.Unbounded
+	if nearEdge && joined {
+		for ty := 0; ty < a.rows; ty++ {
+			a.active[ty*a.columns] = true
+			a.active[ty*a.columns+a.columns-1] = true
+		}
+		for tx := 0; tx < a.columns; tx++ {
+			a.active[tx] = true
+			a.active[(a.rows-1)*a.columns+tx] = true
+		}
+	}
+}
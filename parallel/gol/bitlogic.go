@@ -0,0 +1,138 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// bitPacked reports whether the world can be stepped as a util.BitWorld, which needs a rule with two states
+// that only counts the 8 neighbours of a square cell and leaves nothing to chance, and a world that does not grow.
+func bitPacked(rule util.Rule, topology util.Topology) bool {
+	return rule.States == 2 && !rule.Isotropic && !rule.LargerThanLife() && rule.Tree == nil && !rule.Stochastic() &&
+		rule.Grid == util.SquareGrid && rule.Block == nil && !rule.Cubic && topology != util.Unbounded
+}
+
+// bitValue gives cell (x, y) as a bit, for coordinates outside of the world as well.
+func bitValue(world *util.BitWorld, topology util.Topology, x, y int) uint64 {
+	if x < 0 || y < 0 || x >= world.Width || y >= world.Height {
+		var ok bool
+		if x, y, ok = topology.Map(x, y, world.Width, world.Height); !ok {
+			if topology.Edge() == live {
+				return 1
+			}
+			return 0
+		}
+	}
+	if world.Alive(x, y) {
+		return 1
+	}
+	return 0
+}
+
+// bitRow gives row y of the world. A row beyond the top or bottom edge is made cell by cell in edge.
+func bitRow(world *util.BitWorld, topology util.Topology, y int, edge []uint64) []uint64 {
+	if y >= 0 && y < world.Height {
+		return world.Rows[y]
+	}
+	row := edge
+	for i := range row {
+		row[i] = 0
+	}
+	for x := 0; x < world.Width; x++ {
+		row[x/64] |= bitValue(world, topology, x, y) << (x % 64)
+	}
+	return row
+}
+
+// bitCounter holds a count of 0 to 8 for each of 64 cells, one bit of the count per word.
+type bitCounter struct {
+	b0, b1, b2, b3 uint64
+}
+
+// add adds 1 to the count of the cells whose bit is set.
+func (c *bitCounter) add(bits uint64) {
+	carry0 := c.b0 & bits
+	c.b0 ^= bits
+	carry1 := c.b1 & carry0
+	c.b1 ^= carry0
+	carry2 := c.b2 & carry1
+	c.b2 ^= carry1
+	c.b3 |= carry2
+}
+
+// equals gives the cells whose count is n.
+func (c *bitCounter) equals(n int) uint64 {
+	match := ^uint64(0)
+	for i, b := range [4]uint64{c.b0, c.b1, c.b2, c.b3} {
+		if n&(1<<i) != 0 {
+			match &= b
+		} else {
+			match &^= b
+		}
+	}
+	return match
+}
+
+// CalculateBitNextState is CalculateNextState on a packed world. The 8 neighbours of 64 cells are counted at once
+// by shifting the rows above, below and of the cells by one cell each way. edges holds room for the rows
+// beyond the top and bottom edges of the world. Words of tiles that are not active keep their value, which newRows
+// already holds. The live and flipped cells are added to found from the words in the same sweep.
+// startX and endX are multiples of 64, but endX can also be the width of the world.
+func CalculateBitNextState(p Params, rule util.Rule, topology util.Topology, startX, endX, startY, endY int, world *util.BitWorld, newRows [][]uint64, edges [2][]uint64, active *activeTiles, found *sweepCells) {
+	words := util.Words(p.ImageWidth)
+	lastBit := uint((p.ImageWidth - 1) % 64)
+	lastMask := ^uint64(0) >> (63 - lastBit)
+
+	for y := startY; y < endY; y++ {
+		var rows [3][]uint64
+		var leftEdge, rightEdge [3]uint64
+		for r := range rows {
+			rows[r] = bitRow(world, topology, y+r-1, edges[r/2])
+			leftEdge[r] = bitValue(world, topology, -1, y+r-1)
+			rightEdge[r] = bitValue(world, topology, p.ImageWidth, y+r-1)
+		}
+
+		newRow := newRows[y]
+		for i := startX / 64; i < util.Words(endX); i++ {
+			if !active.isActive(64*i, y) {
+				found.alive = util.AppendCells(found.alive, rows[1][i], 64*i, y)
+				continue
+			}
+			var counter bitCounter
+			for r, row := range rows {
+				// bit x of west is cell x-1, bit x of east is cell x+1
+				west := row[i]<<1 | leftEdge[r]
+				if i > 0 {
+					west = row[i]<<1 | row[i-1]>>63
+				}
+				east := row[i] >> 1
+				if i < words-1 {
+					east |= row[i+1] << 63
+				} else {
+					east |= rightEdge[r] << lastBit
+				}
+				counter.add(west)
+				counter.add(east)
+				if r != 1 {
+					counter.add(row[i])
+				}
+			}
+
+			cells := rows[1][i]
+			var next uint64
+			for n := 0; n <= 8; n++ {
+				if rule.Birth[n] {
+					next |= counter.equals(n) &^ cells
+				}
+				if rule.Survive[n] {
+					next |= counter.equals(n) & cells
+				}
+			}
+			if i == words-1 {
+				next &= lastMask
+			}
+			newRow[i] = next
+			found.alive = util.AppendCells(found.alive, next, 64*i, y)
+			found.flipped = util.AppendCells(found.flipped, next^cells, 64*i, y)
+		}
+	}
+}
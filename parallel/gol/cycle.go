@@ -0,0 +1,82 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// cycleWindow is how many of the last turns a cycleDetector remembers, which bounds the periods it finds.
+const cycleWindow = 1 << 16
+
+// oddTurnKey is mixed into the hashes of odd turns under block rules, whose next partition depends on the turn.
+const oddTurnKey = 0x9e3779b97f4a7c15
+
+// cycleDetector finds the first turn whose world is the same as the world of an earlier turn, after which
+// the run repeats itself. It keeps a Zobrist hash of the world (see util.CellHash), which each turn rolls
+// forward with the cells that changed, so the world is never hashed whole after the first turn.
+// Block rules alternate between two partitions, so their worlds only repeat the run when their turns have
+// the same parity as well. Stochastic rules roll new dice every turn, so a world that comes back does not
+// repeat the run and they are not detected at all.
+type cycleDetector struct {
+	hash   uint64
+	blocks bool           // whether the turn parity is part of the hash, for block rules
+	seen   map[uint64]int // first turn of the window each hash was seen in
+	hashes []uint64       // hash of each turn of the window, by turn modulo cycleWindow
+	latest int            // last turn seen
+}
+
+// newCycleDetector starts detecting cycles from the world of turn, whose cells lie in the universe
+// from origin, under a block rule when blocks is set.
+func newCycleDetector(world [][]byte, origin util.Cell, turn int, blocks bool) *cycleDetector {
+	d := &cycleDetector{
+		blocks: blocks,
+		seen:   make(map[uint64]int),
+		hashes: make([]uint64, cycleWindow),
+		latest: turn - 1,
+	}
+	for y, row := range world {
+		for x, value := range row {
+			d.hash ^= util.CellHash(x+origin.X, y+origin.Y, value)
+		}
+	}
+	d.see(turn)
+	return d
+}
+
+// change rolls the hash forward or back over cells going from the before to the after values,
+// which are nil for rules with two states, where each cell flips between dead and live.
+func (d *cycleDetector) change(cells []util.Cell, before, after []byte) {
+	for i, cell := range cells {
+		if after == nil {
+			d.hash ^= util.CellHash(cell.X, cell.Y, live)
+		} else {
+			d.hash ^= util.CellHash(cell.X, cell.Y, before[i]) ^ util.CellHash(cell.X, cell.Y, after[i])
+		}
+	}
+}
+
+// see records the hash of the world of turn, which follows the last turn seen. It gives the turn
+// the same world was first seen in, if there was one within the window.
+func (d *cycleDetector) see(turn int) (int, bool) {
+	d.latest = turn
+	i := turn % cycleWindow
+	if old := turn - cycleWindow; old >= 0 && d.seen[d.hashes[i]] == old {
+		delete(d.seen, d.hashes[i])
+	}
+	hash := d.hash
+	if d.blocks && turn&1 == 1 {
+		hash ^= oddTurnKey
+	}
+	d.hashes[i] = hash
+	if first, ok := d.seen[hash]; ok {
+		return first, true
+	}
+	d.seen[hash] = turn
+	return 0, false
+}
+
+// forget drops the turns after turn, which a run stepped back through its history will step again.
+func (d *cycleDetector) forget(turn int) {
+	for ; d.latest > turn; d.latest-- {
+		if h := d.hashes[d.latest%cycleWindow]; d.seen[h] == d.latest {
+			delete(d.seen, h)
+		}
+	}
+}
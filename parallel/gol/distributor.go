@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"image"
 	"sync"
 	"time"
 	"uk.ac.bris.cs/gameoflife/util"
@@ -14,6 +15,10 @@ type distributorChannels struct {
 	ioFilename chan<- string
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
+	ioRegion   chan<- image.Rectangle
+	ioCells    chan<- []util.Cell
+	ioFrames   chan<- [][][]byte
+	ioError    <-chan error
 	keyPressCh <-chan rune
 }
 
@@ -22,34 +27,166 @@ func checkIoIdle(c distributorChannels) {
 	<-c.ioIdle
 }
 
-func loadWorld(p Params, c distributorChannels) [][]byte {
+// checkParams gives the rule and the topology of the params, or the error that keeps them from running.
+func checkParams(p Params) (util.Rule, util.Topology, error) {
+	rule, err := util.ParseRule(p.Rule)
+	if err != nil {
+		return rule, 0, err
+	}
+	topology, err := util.ParseTopology(p.Topology)
+	if err != nil {
+		return rule, topology, err
+	}
+	for _, err := range []error{topology.Check(&rule), rule.CheckDepth(topology, p.Depth()), CheckEngine(p), CheckFiles(p)} {
+		if err != nil {
+			return rule, topology, err
+		}
+	}
+	return rule, topology, nil
+}
+
+// loadWorld reads the image of the params, or gives the error that kept the io goroutine from reading it.
+// A 3D world gets the image as its middle slice, and its other slices start dead.
+func loadWorld(p Params, c distributorChannels) ([][]byte, error) {
 	inFileName := fmt.Sprintf("%vx%v", p.ImageWidth, p.ImageHeight)
+	if p.Input != "" {
+		inFileName = p.Input
+	}
 	checkIoIdle(c)
 	// start reading the pgm file if io is idle
 	c.ioCommand <- ioInput
 	c.ioFilename <- inFileName
-	world := util.MakeWorld(p.ImageWidth, p.ImageHeight)
-	for _, w := range world {
+	if err := <-c.ioError; err != nil {
+		return nil, err
+	}
+	world := util.MakeWorld(p.ImageWidth, p.ImageHeight*p.Depth())
+	middle := p.Depth() / 2 * p.ImageHeight
+	for _, w := range world[middle : middle+p.ImageHeight] {
 		for i := range w {
 			w[i] = <-c.ioInput
 		}
 	}
-	return world
+	return world, nil
+}
+
+// exportWorld outputs the world of the state in each output format of the params, after the recording
+// of the run so far when the params record one.
+func exportWorld(p Params, c distributorChannels, state GameState, rec *recorder) {
+	if rec != nil {
+		frames := rec.recording(state.Turn)
+		outFileName := fmt.Sprintf("%vx%vx%v.%v", len(frames[0][0]), len(frames[0]), state.Turn, recordingFormat)
+		outputFrames(c, outFileName, frames, state.Turn)
+	}
+	if topology, _ := util.ParseTopology(p.Topology); topology == util.Unbounded {
+		exportRegion(p, c, state)
+		return
+	}
+	if state.World == nil {
+		state.World = state.Bits.Unpack()
+	}
+	if p.Depth() > 1 {
+		exportSlices(p, c, state)
+		return
+	}
+	outputImage(p, c, fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, state.Turn), state.World, state.Turn, nil)
+}
+
+// exportSlices is exportWorld for a 3D world. It outputs an image for each slice, named after its depth as well,
+// e.g. 64x64x16x100_z3.pgm for slice 3 of a 64x64x16 world after turn 100.
+func exportSlices(p Params, c distributorChannels, state GameState) {
+	for z := 0; z < p.Depth(); z++ {
+		outFileName := fmt.Sprintf("%vx%vx%vx%v_z%v", p.ImageWidth, p.ImageHeight, p.Depth(), state.Turn, z)
+		outputImage(p, c, outFileName, state.World[z*p.ImageHeight:(z+1)*p.ImageHeight], state.Turn, nil)
+	}
+}
+
+// exportRegion is exportWorld for an unbounded universe. It only outputs the bounding box of the cells
+// that are not dead, and records where it lies in the universe.
+func exportRegion(p Params, c distributorChannels, state GameState) {
+	world, origin := state.World, state.Origin
+	if world == nil {
+		// HashLife only keeps the live cells of an unbounded universe
+		var err error
+		if world, origin, err = worldFromCells(state.AliveCells); err != nil {
+			// the live cells can still be listed, however far apart they lie
+			box := cellsBoundingBox(state.AliveCells)
+			outFileName := fmt.Sprintf("%vx%vx%v", box.Dx(), box.Dy(), state.Turn)
+			for _, format := range outputFormats(p) {
+				if format == LifeFormat {
+					outputCells(c, outputName(outFileName, format), state.AliveCells, state.Turn)
+				} else {
+					c.events <- IoError{state.Turn, fmt.Errorf("cannot output %v: %v", outputName(outFileName, format), err)}
+				}
+			}
+			return
+		}
+	}
+	world, box := util.CropWorld(world)
+	region := box.Add(image.Pt(origin.X, origin.Y))
+	outFileName := fmt.Sprintf("%vx%vx%v", region.Dx(), region.Dy(), state.Turn)
+	outputImage(p, c, outFileName, world, state.Turn, &region)
+}
+
+// outputImage has the io goroutine write a world to a file in each output format of the params, and reports
+// each file. A world of an unbounded universe is given the region of the universe it lies in, and others nil.
+func outputImage(p Params, c distributorChannels, name string, world [][]byte, turn int, region *image.Rectangle) {
+	for _, format := range outputFormats(p) {
+		outFileName := outputName(name, format)
+		if format == LifeFormat {
+			var origin util.Cell
+			if region != nil {
+				origin = util.Cell{X: region.Min.X, Y: region.Min.Y}
+			}
+			outputCells(c, outFileName, util.LiveCells(world, origin), turn)
+			continue
+		}
+		checkIoIdle(c)
+		if region == nil {
+			c.ioCommand <- ioOutput
+			c.ioFilename <- outFileName
+		} else {
+			c.ioCommand <- ioOutputRegion
+			c.ioFilename <- outFileName
+			c.ioRegion <- *region
+		}
+		for _, w := range world {
+			for i := range w {
+				c.ioOutput <- w[i]
+			}
+		}
+		// The io goroutine answers once all bytes of the world have been written, before ImageOutputComplete is sent
+		if err := <-c.ioError; err != nil {
+			c.events <- IoError{turn, err}
+			continue
+		}
+		c.events <- ImageOutputComplete{turn, outFileName}
+	}
 }
 
-func exportWorld(p Params, c distributorChannels, state GameState) {
+// outputCells has the io goroutine write a list of live cells to a Life 1.06 file, and reports the file.
+func outputCells(c distributorChannels, outFileName string, cells []util.Cell, turn int) {
 	checkIoIdle(c)
-	outFileName := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, state.Turn)
-	c.ioCommand <- ioOutput
+	c.ioCommand <- ioOutputCells
 	c.ioFilename <- outFileName
-	for _, w := range state.World {
-		for i := range w {
-			c.ioOutput <- w[i]
-		}
+	c.ioCells <- cells
+	if err := <-c.ioError; err != nil {
+		c.events <- IoError{turn, err}
+		return
 	}
-	// Make sure all byte in the world has been passed to the output channel before sending ImageOutputComplete
+	c.events <- ImageOutputComplete{turn, outFileName}
+}
+
+// outputFrames has the io goroutine write the frames of a recording to an animated gif file, and reports the file.
+func outputFrames(c distributorChannels, outFileName string, frames [][][]byte, turn int) {
 	checkIoIdle(c)
-	c.events <- ImageOutputComplete{state.Turn, outFileName}
+	c.ioCommand <- ioOutputFrames
+	c.ioFilename <- outFileName
+	c.ioFrames <- frames
+	if err := <-c.ioError; err != nil {
+		c.events <- IoError{turn, err}
+		return
+	}
+	c.events <- ImageOutputComplete{turn, outFileName}
 }
 
 func reportAliveCells(c distributorChannels, mu *sync.Mutex, quitCh <-chan bool) {
@@ -78,6 +215,10 @@ func manageKeyPress(c distributorChannels, chs *KeyPressChannels, quitCh chan bo
 				chs.QuitChannel <- true
 			case 'p':
 				chs.PauseChannel <- true
+			case 'b':
+				chs.BackChannel <- true
+			case 'f':
+				chs.ForwardChannel <- true
 			}
 		case <-quitCh:
 			break
@@ -85,13 +226,27 @@ func manageKeyPress(c distributorChannels, chs *KeyPressChannels, quitCh chan bo
 	}
 }
 
-func quitGol(c distributorChannels, p Params, quitAliveCellsCh chan<- bool, quitKeyPress chan<- bool) {
+// replayed reports a turn stepped back or forward to through the history, in the same way as a turn
+// that was stepped, so that the SDL window follows.
+func replayed(c distributorChannels, pool *workerPool, rec *recorder, cells []util.Cell, values []byte, aliveCells []util.Cell, turn int) {
+	if packed := pool.packedWorld(); packed != nil {
+		gameState.UpdateBits(packed, aliveCells, turn)
+	} else {
+		gameState.Update(pool.world(), aliveCells, turn)
+	}
+	flipped := CellsFlipped{CompletedTurns: turn, Cells: cells, Values: values}
+	rec.see(flipped)
+	c.events <- flipped
+	c.events <- TurnComplete{CompletedTurns: turn}
+}
+
+func quitGol(c distributorChannels, p Params, rec *recorder, reason StopReason, quitAliveCellsCh chan<- bool, quitKeyPress chan<- bool) {
 	quitAliveCellsCh <- true
 	quitKeyPress <- true
 
-	c.events <- FinalTurnComplete{CompletedTurns: gameState.Turn, Alive: gameState.AliveCells}
+	c.events <- FinalTurnComplete{CompletedTurns: gameState.Turn, Alive: gameState.AliveCells, Reason: reason}
 
-	exportWorld(p, c, gameState)
+	exportWorld(p, c, gameState, rec)
 
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
@@ -108,12 +263,23 @@ func distributor(p Params, c distributorChannels) {
 	var stateMutex sync.Mutex
 	gameState.Pause = false
 
+	rule, topology, err := checkParams(p)
 	// TODO: Create a 2D slice to store the world.
-	inputWorld := loadWorld(p, c)
+	var inputWorld [][]byte
+	if err == nil {
+		inputWorld, err = loadWorld(p, c)
+	}
+	if err != nil {
+		// there is nothing to run with params that cannot run or without the first world
+		c.events <- IoError{0, err}
+		c.events <- StateChange{0, Quitting}
+		close(c.events)
+		return
+	}
 	turn := 0
 
-	immutableWorld := util.MakeImmutableWorld(inputWorld)
-	aliveCells := CalculateAliveCells(p, 0, p.ImageHeight, immutableWorld)
+	immutableWorld := util.MakeTopologyWorld(inputWorld, topology)
+	aliveCells := CalculateAliveCells(p, 0, p.ImageHeight*p.Depth(), immutableWorld, nil)
 
 	workerChs := new(WorkerChannels)
 	workerChs.InitialiseChannels(p)
@@ -121,11 +287,44 @@ func distributor(p Params, c distributorChannels) {
 	keyPressChs := new(KeyPressChannels)
 	keyPressChs.InitialiseChannels()
 
-	c.events <- CellsFlipped{turn, aliveCells}
+	// the recording follows the world from the same events as the SDL window
+	rec := newRecorder(p)
+	first := CellsFlipped{turn, aliveCells, nil}
+	if rule.States > 2 {
+		emptyWorld := util.MakeImmutableWorld(util.MakeWorld(p.ImageWidth, p.ImageHeight))
+		cells, values := calculateChangedCells(p, emptyWorld, immutableWorld)
+		first = CellsFlipped{turn, cells, values}
+	}
+	rec.see(first)
+	c.events <- first
 	c.events <- StateChange{turn, Executing}
 
 	gameState.Update(inputWorld, aliveCells, turn)
 
+	// An unbounded universe keeps the image at the origin, and grows the world around it.
+	origin := util.Cell{}
+	gameState.Origin = origin
+
+	var life *hashLife
+	if p.Engine == HashLifeEngine {
+		life = newHashLife(rule, topology, inputWorld)
+	}
+
+	var pool *workerPool
+	var past *history
+	var cycles *cycleDetector
+	if life == nil {
+		pool = newWorkerPool(p, rule, topology, inputWorld, workerChs)
+		defer pool.stop()
+		past = newHistory(p.History)
+		if !rule.Stochastic() {
+			cycles = newCycleDetector(inputWorld, origin, turn, rule.Block != nil)
+		}
+	}
+	// the run finishes early once reason is set, see stopConditions
+	until := newStopConditions(p, turn)
+	reason := until.check(turn, aliveCells)
+
 	quitAliveCellsCh := make(chan bool)
 	quitKeyPress := make(chan bool)
 
@@ -133,12 +332,12 @@ func distributor(p Params, c distributorChannels) {
 	go manageKeyPress(c, keyPressChs, quitKeyPress)
 
 	// TODO: Execute all turns of the Game of Life.
-	for turn < p.Turns {
+	for turn < p.Turns && reason == TurnsCompleted {
 		// if receive key signal process it, otherwise run gol
 		select {
 		case <-keyPressChs.SaveChannel:
 			stateMutex.Lock()
-			exportWorld(p, c, gameState)
+			exportWorld(p, c, gameState, rec)
 			stateMutex.Unlock()
 
 		case <-keyPressChs.PauseChannel:
@@ -148,43 +347,128 @@ func distributor(p Params, c distributorChannels) {
 				c.events <- StateChange{CompletedTurns: gameState.Turn, NewState: Paused}
 			} else {
 				c.events <- StateChange{CompletedTurns: gameState.Turn, NewState: Executing}
+				until.resume(turn)
+			}
+			stateMutex.Unlock()
+
+		case <-keyPressChs.BackChannel:
+			// a paused run steps back one turn, undoing the cells the turn flipped
+			stateMutex.Lock()
+			if gameState.Pause {
+				if diff, ok := past.back(); ok {
+					turn--
+					aliveCells = pool.replay(diff.cells, diff.before, origin)
+					if cycles != nil {
+						cycles.forget(turn)
+						cycles.change(diff.cells, diff.after, diff.before)
+					}
+					replayed(c, pool, rec, diff.cells, diff.before, aliveCells, turn)
+				}
+			}
+			stateMutex.Unlock()
+
+		case <-keyPressChs.ForwardChannel:
+			// and forward again through the turns it stepped back through
+			stateMutex.Lock()
+			if gameState.Pause {
+				if diff, ok := past.forward(); ok {
+					turn++
+					aliveCells = pool.replay(diff.cells, diff.after, origin)
+					if cycles != nil {
+						cycles.change(diff.cells, diff.before, diff.after)
+						cycles.see(turn)
+					}
+					replayed(c, pool, rec, diff.cells, diff.after, aliveCells, turn)
+				}
 			}
 			stateMutex.Unlock()
 
 		case <-keyPressChs.QuitChannel:
 			// quit all goroutines
 			stateMutex.Lock()
-			quitGol(c, p, quitAliveCellsCh, quitKeyPress)
+			quitGol(c, p, rec, QuitPressed, quitAliveCellsCh, quitKeyPress)
 			stateMutex.Unlock()
 			close(c.events)
 			return
 
 		default:
-			if !gameState.Pause {
-				turn++
-				go DelegateStateWork(p, immutableWorld, workerChs.StateWorkerChannels, workerChs.NextStateChannel)
-				nextStateWorld := <-workerChs.NextStateChannel
-
-				immutableWorld = util.MakeImmutableWorld(nextStateWorld)
-
-				go DelegateCellWork(p, immutableWorld, workerChs.CellWorkerChannels, workerChs.NextAliveCellsChannel)
-				nextAliveCells := <-workerChs.NextAliveCellsChannel
-
+			if !gameState.Pause && life != nil {
+				// HashLife jumps many turns at once, checking key presses in between
+				turn += life.Step(p.Turns - turn)
+				nextAliveCells := life.AliveCells()
+				nextStateWorld := life.World(nextAliveCells)
 				flipped := calculateFlippedCells(aliveCells, nextAliveCells)
 
 				stateMutex.Lock()
 				gameState.Update(nextStateWorld, nextAliveCells, turn)
-				c.events <- CellsFlipped{CompletedTurns: gameState.Turn, Cells: flipped}
+				changed := CellsFlipped{CompletedTurns: gameState.Turn, Cells: flipped}
+				rec.see(changed)
+				c.events <- changed
+				c.events <- TurnComplete{CompletedTurns: gameState.Turn}
+				stateMutex.Unlock()
+
+				aliveCells = nextAliveCells
+				reason = until.check(turn, aliveCells)
+			} else if !gameState.Pause {
+				turn++
+				if topology == util.Unbounded {
+					if grown, shift, ok := util.GrowWorld(pool.world(), rule.Range); ok {
+						origin = util.Cell{X: origin.X - shift.X, Y: origin.Y - shift.Y}
+						p.ImageWidth, p.ImageHeight = len(grown[0]), len(grown)
+						pool.load(p, grown)
+					}
+				}
+				nextAliveCells, flipped, values := pool.step(util.Noise{Seed: p.Seed, Turn: turn, Origin: origin})
+				var before []byte
+				if (past != nil || cycles != nil) && values != nil {
+					before = pool.previousValues(flipped)
+				}
+				if topology == util.Unbounded {
+					nextAliveCells = util.Translate(nextAliveCells, origin)
+					flipped = util.Translate(flipped, origin)
+				}
+				if past != nil {
+					past.record(turnDiff{cells: flipped, before: before, after: values})
+				}
+				var cycle *CycleDetected
+				if cycles != nil {
+					cycles.change(flipped, before, values)
+					if first, ok := cycles.see(turn); ok {
+						// a cycle is only reported the first time round
+						cycle = &CycleDetected{CompletedTurns: turn, Period: turn - first, FirstSeenTurn: first}
+						cycles = nil
+					}
+				}
+
+				stateMutex.Lock()
+				if packed := pool.packedWorld(); packed != nil {
+					gameState.UpdateBits(packed, nextAliveCells, turn)
+				} else {
+					gameState.Update(pool.world(), nextAliveCells, turn)
+				}
+				gameState.Origin = origin
+				changed := CellsFlipped{CompletedTurns: gameState.Turn, Cells: flipped, Values: values}
+				rec.see(changed)
+				c.events <- changed
 				c.events <- TurnComplete{CompletedTurns: gameState.Turn}
+				if cycle != nil {
+					c.events <- *cycle
+					if p.StopOnCycle {
+						reason = CycleFound
+					}
+				}
 				stateMutex.Unlock()
 
 				aliveCells = nextAliveCells
+				if reason == TurnsCompleted {
+					reason = until.check(turn, aliveCells)
+				}
 			}
 		}
 	}
 
 	stateMutex.Lock()
-	quitGol(c, p, quitAliveCellsCh, quitKeyPress)
+	quitGol(c, p, rec, reason, quitAliveCellsCh, quitKeyPress)
 	stateMutex.Unlock()
 
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
@@ -16,6 +16,7 @@ type Event interface {
 }
 
 // `AliveCellsCount` is an Event notifying the user about the number of currently alive cells.
+// Only cells in the live state are counted, dying cells of Generations rules are not.
 // This Event should be sent every 2s.
 type AliveCellsCount struct { // implements Event
 	CompletedTurns int
@@ -29,6 +30,14 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// `IoError` is an Event notifying the user that a file could not be read or written.
+// A run whose params are refused or whose input cannot be read sends it before quitting,
+// and a run whose image cannot be output goes on.
+type IoError struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -38,6 +47,26 @@ const (
 	Quitting
 )
 
+// StopReason tells why a run finished.
+type StopReason int
+
+const (
+	TurnsCompleted   StopReason = iota // every turn of Params.Turns was stepped
+	QuitPressed                        // q was pressed
+	CycleFound                         // the world repeated itself with Params.StopOnCycle set
+	Extinct                            // no cell was alive with Params.StopOnExtinction set
+	PopulationBelow                    // fewer cells were alive than Params.MinPopulation
+	PopulationAbove                    // more cells were alive than Params.MaxPopulation
+	BoxUnchanged                       // the live cells kept their bounding box for Params.StableBox turns
+	TimeLimitReached                   // the run took longer than Params.TimeLimit
+	TooSlow                            // the run stepped fewer turns a second than Params.MinSpeed
+)
+
+var stopReasonNames = []string{
+	"Turns Completed", "Quit Pressed", "Cycle Found", "Extinct", "Population Below Minimum",
+	"Population Above Maximum", "Bounding Box Unchanged", "Time Limit Reached", "Too Slow",
+}
+
 // `StateChange` is an Event notifying the user about the change of state of execution.
 // This Event should be sent every time the execution is paused, resumed or quit.
 type StateChange struct { // implements Event
@@ -58,9 +87,14 @@ type CellFlipped struct { // implements Event
 // You can send many times of `CellsFlipped` event in a turn, i.e., each worker could send `CellsFlipped`.
 // **Please be careful not to send `CellFlipped` and `CellsFlipped` at the same time, as they may conflict.**
 // Choose one of them.
+// For Generations rules a cell can change state without flipping between dead and alive, e.g. when it decays.
+// `Cells` then lists every cell whose state changed and `Values` holds the new grey level of each of them.
+// `Values` is nil for rules with two states, where every change is a flip.
+// In an unbounded universe cells are given in universe coordinates, which can be negative or lie beyond the image.
 type CellsFlipped struct { // implements Event
 	CompletedTurns int
 	Cells          []util.Cell
+	Values         []byte
 }
 
 // `TurnComplete` is an Event notifying the GUI about turn completion.
@@ -73,9 +107,21 @@ type TurnComplete struct { // implements Event
 // `FinalTurnComplete` is an Event notifying the testing framework about the new world state after execution finished.
 // The data included with this Event is used directly by the tests.
 // SDL closes the window when this Event is sent.
+// `Alive` uses universe coordinates in an unbounded universe, like `CellsFlipped`.
+// `Reason` tells why the run finished, which is `TurnsCompleted` unless a stopping condition of the params was met.
 type FinalTurnComplete struct {
 	CompletedTurns int
 	Alive          []util.Cell
+	Reason         StopReason
+}
+
+// `CycleDetected` is an Event notifying the user that the world of a turn is the same as the world of an earlier turn,
+// so the run repeats itself from then on with the given period: 1 for a world that stopped changing, 2 for blinkers.
+// `FirstSeenTurn` is the turn the world was first seen in. It is sent once, after the `TurnComplete` of the turn.
+type CycleDetected struct { // implements Event
+	CompletedTurns int
+	Period         int
+	FirstSeenTurn  int
 }
 
 // String methods allow the different types of Events and States to be printed.
@@ -93,6 +139,13 @@ func (state State) String() string {
 	}
 }
 
+func (reason StopReason) String() string {
+	if reason < 0 || int(reason) >= len(stopReasonNames) {
+		return "Incorrect Stop Reason"
+	}
+	return stopReasonNames[reason]
+}
+
 func (event StateChange) String() string {
 	return fmt.Sprintf("%v", event.NewState)
 }
@@ -117,6 +170,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event IoError) String() string {
+	return fmt.Sprintf("File Error: %v", event.Err)
+}
+
+func (event IoError) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return ""
 }
@@ -142,13 +203,21 @@ func (event TurnComplete) GetCompletedTurns() int {
 }
 
 func (event FinalTurnComplete) String() string {
-	return "Final Turn Complete"
+	return fmt.Sprintf("Final Turn Complete: %v", event.Reason)
 }
 
 func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	return fmt.Sprintf("Cycle of period %v since turn %v", event.Period, event.FirstSeenTurn)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -0,0 +1,108 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// rowSums holds the running sums a worker uses for Larger than Life rules. They are kept between turns,
+// so they are only allocated again when a worker steps a bigger part of the world than before.
+type rowSums struct {
+	sums    [][]int
+	areas   [][]int
+	extents []int
+}
+
+// resize makes room for the sums of rows padded rows of width cells, keeping the room it already has.
+func (s *rowSums) resize(rows, width int) {
+	if len(s.sums) >= rows && len(s.sums[0]) >= width {
+		return
+	}
+	if len(s.sums) > 0 {
+		rows, width = maxInt(rows, len(s.sums)), maxInt(width, len(s.sums[0]))
+	}
+	s.sums = make([][]int, rows)
+	s.areas = make([][]int, rows+1)
+	for i := range s.areas {
+		s.areas[i] = make([]int, width)
+		if i < rows {
+			s.sums[i] = make([]int, width)
+		}
+	}
+}
+
+// calculateRowSums fills in running sums of the live cells along the cells startX-r to endX+r-1 of the rows
+// startY-r to endY+r-1 of the world, past its edges as its topology says. sums[i][k] counts the live cells
+// among the first k of these cells in row i.
+func calculateRowSums(r, startX, endX, startY, endY int, immutableWorld func(int, int) byte, sums [][]int) {
+	for i, row := range sums[:endY-startY+2*r] {
+		y := startY - r + i
+		for k := 0; k < endX-startX+2*r; k++ {
+			row[k+1] = row[k]
+			if immutableWorld(y, startX+k-r) == live {
+				row[k+1]++
+			}
+		}
+	}
+}
+
+// CalculateExtendedNextState is CalculateNextState for Larger than Life rules, whose neighbourhoods reach
+// rule.Range cells away. Counts come from running sums along the rows, so a cell costs one subtraction per row
+// of its neighbourhood instead of one read per neighbour. Moore neighbourhoods also sum the rows up,
+// which brings the cost down to four reads whatever the range.
+func CalculateExtendedNextState(p Params, rule util.Rule, startX, endX, startY, endY int, immutableWorld func(int, int) byte, newWorld [][]byte, s *rowSums, active *activeTiles, found *sweepCells) {
+	r := rule.Range
+	rows, width := endY-startY+2*r, endX-startX+2*r+1
+	s.resize(rows, width)
+	sums := s.sums
+	calculateRowSums(r, startX, endX, startY, endY, immutableWorld, sums)
+
+	var areas [][]int
+	if rule.Neighbourhood == util.Moore {
+		areas = s.areas
+		for i, row := range sums[:rows] {
+			for k := 0; k < width; k++ {
+				areas[i+1][k] = areas[i][k] + row[k]
+			}
+		}
+	}
+
+	if len(s.extents) != 2*r+1 {
+		s.extents = make([]int, 2*r+1)
+	}
+	extents := s.extents
+	for dy := -r; dy <= r; dy++ {
+		extents[dy+r] = rule.RowExtent(dy)
+	}
+
+	stepping := true
+	for y := startY; y < endY; y++ {
+		i := y - startY + r // row of y in sums
+		for x := startX; x < endX; x++ {
+			if x == startX || x%tileWidth == 0 {
+				stepping = active.isActive(x, y)
+			}
+			value := immutableWorld(y, x)
+			if !stepping {
+				found.add(x, y, value, value)
+				continue
+			}
+			k := x - startX + r // column of x in the padded rows
+			counter := 0
+			if areas != nil {
+				counter = areas[i+r+1][k+r+1] - areas[i-r][k+r+1] - areas[i+r+1][k-r] + areas[i-r][k-r]
+			} else {
+				for dy := -r; dy <= r; dy++ {
+					e := extents[dy+r]
+					counter += sums[i+dy][k+e+1] - sums[i+dy][k-e]
+				}
+			}
+
+			if value == live && !rule.Middle {
+				counter--
+			}
+			next := rule.Next(value, counter)
+			newWorld[y][x] = next
+			found.add(x, y, value, next)
+		}
+	}
+}
@@ -9,10 +9,21 @@ type GameState struct {
 	AliveCells []util.Cell
 	Turn       int
 	Pause      bool
+	Origin     util.Cell      // universe cell at the top left of World, for unbounded universes
+	Bits       *util.BitWorld // the world while it is stepped packed, when World is nil
 }
 
 func (s *GameState) Update(world [][]byte, aliveCells []util.Cell, turn int) {
 	s.World = world
+	s.Bits = nil
+	s.AliveCells = aliveCells
+	s.Turn = turn
+}
+
+// UpdateBits is Update for a packed world, which is only unpacked when it is output.
+func (s *GameState) UpdateBits(bits *util.BitWorld, aliveCells []util.Cell, turn int) {
+	s.World = nil
+	s.Bits = bits
 	s.AliveCells = aliveCells
 	s.Turn = turn
 }
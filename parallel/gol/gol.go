@@ -1,11 +1,44 @@
 package gol
 
+import (
+	"image"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	ImageDepth  int    // number of slices of a 3D world, stacked below each other; 0 means 1
+	Rule        string // rulestring in B/S notation, e.g. "B36/S23"; empty means B3/S23
+	Topology    string // how the edges of the world are joined, see util.ParseTopology; empty means torus
+	Engine      string // StripsEngine or HashLifeEngine; empty means StripsEngine
+	TileSize    int    // side of the tiles the workers share out each turn; 0 means DefaultTileSize
+	Seed        uint64 // seed of the random numbers of stochastic rules, see util.Noise
+	History     int    // megabytes of flipped cells kept to step a paused run back; 0 keeps none
+	StopOnCycle bool   // finish once the world repeats itself, see CycleDetected
+
+	// Files the run starts from and writes its images to.
+	Input     string     // path of a pattern or image to start from, e.g. glider.rle; empty reads images/WxH.pgm
+	Offset    *util.Cell // cell the top left of the input pattern is placed at; nil places it in the middle
+	Output    string     // comma-separated formats images are output in, e.g. "pgm,rle"; empty means pgm
+	OutDir    string     // directory images are output to; empty means DefaultOutDir
+	Plain     bool       // output pbm and pgm images in their plain ASCII forms, P1 and P2
+	Threshold int        // grey level from which input pixels are live cells for two-state rules; 0 means DefaultThreshold
+	Scale     int        // side of the square of pixels each cell is drawn as in png and gif images; 0 means 1
+	Palette   string     // colours of the states in png and gif images, see util.ParsePalette; empty means grey
+	Record    int        // turns between the frames of the animated gif of the run, output with its images; 0 records none
+
+	// Conditions that finish a run before its last turn, each of them turned off by its zero value.
+	StopOnExtinction bool          // finish once no cell is alive
+	MinPopulation    int           // finish once fewer cells are alive
+	MaxPopulation    int           // finish once more cells are alive
+	StableBox        int           // finish once the live cells have kept their bounding box for this many turns
+	TimeLimit        time.Duration // finish once the run has taken this long
+	MinSpeed         float64       // finish once the run steps fewer turns a second, counted every 2 seconds
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
@@ -15,6 +48,10 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 	fileCh := make(chan string)
 	outputCh := make(chan uint8)
 	inputCh := make(chan uint8)
+	regionCh := make(chan image.Rectangle)
+	cellsCh := make(chan []util.Cell)
+	framesCh := make(chan [][][]byte)
+	errCh := make(chan error)
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
@@ -25,6 +62,10 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: fileCh,
 		output:   outputCh,
 		input:    inputCh,
+		region:   regionCh,
+		cells:    cellsCh,
+		frames:   framesCh,
+		err:      errCh,
 	}
 	go startIo(p, ioChannels)
 
@@ -35,6 +76,10 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioFilename: fileCh,
 		ioOutput:   outputCh,
 		ioInput:    inputCh,
+		ioRegion:   regionCh,
+		ioCells:    cellsCh,
+		ioFrames:   framesCh,
+		ioError:    errCh,
 		keyPressCh: keyPresses,
 	}
 	distributor(p, distributorChannels)
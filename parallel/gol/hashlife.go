@@ -0,0 +1,436 @@
+package gol
+
+import (
+	"fmt"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Engines that compute the turns, selected with Params.Engine.
+const (
+	StripsEngine   = "strips"   // steps one turn at a time, splitting the world into strips between the threads
+	HashLifeEngine = "hashlife" // jumps many turns at a time with a memoised quadtree
+)
+
+// CheckEngine reports whether the engine of the params can run its rule and topology, and that the tile size
+// is not negative.
+// HashLife needs a rule with two states and the 8 neighbours of a square cell, which is not a block or 3D rule,
+// is not loaded from a rule file and leaves nothing to chance, and either an unbounded universe or a torus
+// whose sides are the same power of two.
+func CheckEngine(p Params) error {
+	if p.TileSize < 0 {
+		return fmt.Errorf("tile size must not be negative, got %v", p.TileSize)
+	}
+	switch p.Engine {
+	case "", StripsEngine:
+		return nil
+	case HashLifeEngine:
+	default:
+		return fmt.Errorf("unknown engine %q, expected %v or %v", p.Engine, StripsEngine, HashLifeEngine)
+	}
+	rule, err := util.ParseRule(p.Rule)
+	if err != nil {
+		return err
+	}
+	if rule.Grid != util.SquareGrid {
+		return fmt.Errorf("the %v engine cannot run rule %v on a %v grid", HashLifeEngine, rule, rule.Grid)
+	}
+	if rule.States > 2 || rule.LargerThanLife() {
+		return fmt.Errorf("the %v engine cannot run rule %v, which needs two states and range 1", HashLifeEngine, rule)
+	}
+	if rule.Tree != nil {
+		return fmt.Errorf("the %v engine cannot run rule %v from a rule file", HashLifeEngine, rule)
+	}
+	if rule.Block != nil {
+		return fmt.Errorf("the %v engine cannot run block rule %v", HashLifeEngine, rule)
+	}
+	if rule.Cubic {
+		return fmt.Errorf("the %v engine cannot run 3D rule %v", HashLifeEngine, rule)
+	}
+	if rule.Stochastic() {
+		return fmt.Errorf("the %v engine cannot run rule %v, whose births and survivals happen by chance", HashLifeEngine, rule)
+	}
+	topology, err := util.ParseTopology(p.Topology)
+	if err != nil {
+		return err
+	}
+	switch topology {
+	case util.Unbounded:
+	case util.Torus:
+		if p.ImageWidth != p.ImageHeight || p.ImageWidth&(p.ImageWidth-1) != 0 || p.ImageWidth < 2 {
+			return fmt.Errorf("the %v engine needs a square torus whose side is a power of two, got %vx%v",
+				HashLifeEngine, p.ImageWidth, p.ImageHeight)
+		}
+	default:
+		return fmt.Errorf("the %v engine cannot run on a %v", HashLifeEngine, topology)
+	}
+	return nil
+}
+
+// node is a square of 2^level cells a side, made of four squares half its size. Nodes are shared:
+// two nodes with the same cells are the same node, so each result only has to be computed once.
+type node struct {
+	nw, ne, sw, se *node
+	level          int
+	population     int
+}
+
+type successorKey struct {
+	n *node
+	j int
+}
+
+// maxNodes is the number of nodes after which the memoised results are dropped to bound the memory used.
+const maxNodes = 1 << 20
+
+// hashLife runs a world with the HashLife algorithm: the centre of a node 2^j turns later is computed from
+// the centres of its sub-nodes, and remembered for every other place the same node appears.
+type hashLife struct {
+	rule     util.Rule
+	topology util.Topology
+
+	root   *node
+	origin util.Cell // universe cell at the top left of root
+	ramp   int       // the next jump is at most 2^ramp turns, which grows after each jump
+
+	dead, live *node
+	empty      []*node // empty[level] has no live cells
+	nodes      map[[4]*node]*node
+	successors map[successorKey]*node
+	torusSteps map[successorKey]*node
+}
+
+func newHashLife(rule util.Rule, topology util.Topology, world [][]byte) *hashLife {
+	h := &hashLife{rule: rule, topology: topology}
+	h.reset()
+
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	level := 1
+	for 1<<level < width || 1<<level < height {
+		level++
+	}
+	h.root = h.build(world, 0, 0, level)
+	return h
+}
+
+func (h *hashLife) reset() {
+	h.dead = &node{}
+	h.live = &node{population: 1}
+	h.empty = []*node{h.dead}
+	h.nodes = make(map[[4]*node]*node)
+	h.successors = make(map[successorKey]*node)
+	h.torusSteps = make(map[successorKey]*node)
+}
+
+// join gives the node made of four nodes one level below.
+func (h *hashLife) join(nw, ne, sw, se *node) *node {
+	key := [4]*node{nw, ne, sw, se}
+	if n, ok := h.nodes[key]; ok {
+		return n
+	}
+	n := &node{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	h.nodes[key] = n
+	return n
+}
+
+func (h *hashLife) emptyNode(level int) *node {
+	for len(h.empty) <= level {
+		e := h.empty[len(h.empty)-1]
+		h.empty = append(h.empty, h.join(e, e, e, e))
+	}
+	return h.empty[level]
+}
+
+// build makes the node of the given level whose top left cell is (x, y) of the world. Cells beyond the world are dead.
+func (h *hashLife) build(world [][]byte, x, y, level int) *node {
+	if y >= len(world) || x >= len(world[0]) {
+		return h.emptyNode(level)
+	}
+	if level == 0 {
+		if world[y][x] == live {
+			return h.live
+		}
+		return h.dead
+	}
+	half := 1 << (level - 1)
+	return h.join(
+		h.build(world, x, y, level-1), h.build(world, x+half, y, level-1),
+		h.build(world, x, y+half, level-1), h.build(world, x+half, y+half, level-1),
+	)
+}
+
+// centre gives the node of half the size in the middle of a node.
+func (h *hashLife) centre(n *node) *node {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+func (h *hashLife) centreHorizontal(w, e *node) *node {
+	return h.join(w.ne, e.nw, w.se, e.sw)
+}
+
+func (h *hashLife) centreVertical(n, s *node) *node {
+	return h.join(n.sw, n.se, s.nw, s.ne)
+}
+
+// cell reports whether the cell (x, y) of a small node is alive.
+func cell(n *node, x, y int) bool {
+	for n.level > 0 {
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n.population == 1
+}
+
+// step gives the middle 2x2 cells of a 4x4 node one turn later.
+func (h *hashLife) step(n *node) *node {
+	var result [4]*node
+	for i := 0; i < 4; i++ {
+		x, y := 1+i%2, 1+i/2
+		counter := 0
+		var neighbourhood uint8
+		for d, dir := range neighbourhoodDir {
+			if cell(n, x+dir[0], y+dir[1]) {
+				counter++
+				neighbourhood |= 1 << d
+			}
+		}
+		value := dead
+		if cell(n, x, y) {
+			value = live
+		}
+		if h.rule.Isotropic {
+			value = h.rule.NextIsotropic(value, neighbourhood)
+		} else {
+			value = h.rule.Next(value, counter)
+		}
+		result[i] = h.dead
+		if value == live {
+			result[i] = h.live
+		}
+	}
+	return h.join(result[0], result[1], result[2], result[3])
+}
+
+// successor gives the centre of a node 2^j turns later, where j is at most its level - 2.
+func (h *hashLife) successor(n *node, j int) *node {
+	if n.population == 0 && !h.rule.Birth[0] && !h.rule.BirthConfigs[0] {
+		return h.emptyNode(n.level - 1)
+	}
+	key := successorKey{n, j}
+	if result, ok := h.successors[key]; ok {
+		return result
+	}
+
+	var result *node
+	if n.level == 2 {
+		result = h.step(n)
+	} else {
+		// the nine overlapping nodes a level below, from the top left to the bottom right
+		n00, n01, n02 := n.nw, h.centreHorizontal(n.nw, n.ne), n.ne
+		n10, n11, n12 := h.centreVertical(n.nw, n.sw), h.centre(n), h.centreVertical(n.ne, n.se)
+		n20, n21, n22 := n.sw, h.centreHorizontal(n.sw, n.se), n.se
+
+		if j == n.level-2 {
+			// advance half the turns on the nine nodes, then the other half on the four they make up
+			c00, c01, c02 := h.successor(n00, j-1), h.successor(n01, j-1), h.successor(n02, j-1)
+			c10, c11, c12 := h.successor(n10, j-1), h.successor(n11, j-1), h.successor(n12, j-1)
+			c20, c21, c22 := h.successor(n20, j-1), h.successor(n21, j-1), h.successor(n22, j-1)
+			result = h.join(
+				h.successor(h.join(c00, c01, c10, c11), j-1), h.successor(h.join(c01, c02, c11, c12), j-1),
+				h.successor(h.join(c10, c11, c20, c21), j-1), h.successor(h.join(c11, c12, c21, c22), j-1),
+			)
+		} else {
+			// advance all the turns on the nine nodes, then take the centres of the four they make up
+			c00, c01, c02 := h.successor(n00, j), h.successor(n01, j), h.successor(n02, j)
+			c10, c11, c12 := h.successor(n10, j), h.successor(n11, j), h.successor(n12, j)
+			c20, c21, c22 := h.successor(n20, j), h.successor(n21, j), h.successor(n22, j)
+			result = h.join(
+				h.centre(h.join(c00, c01, c10, c11)), h.centre(h.join(c01, c02, c11, c12)),
+				h.centre(h.join(c10, c11, c20, c21)), h.centre(h.join(c11, c12, c21, c22)),
+			)
+		}
+	}
+	h.successors[key] = result
+	return result
+}
+
+// Step advances the world by the largest power of two turns allowed by the ramp that is at most maxTurns,
+// and gives the number of turns it advanced.
+func (h *hashLife) Step(maxTurns int) int {
+	j := 0
+	for j < h.ramp && 2<<j <= maxTurns {
+		j++
+	}
+	if h.ramp < 62 {
+		h.ramp++
+	}
+	if h.topology == util.Torus {
+		h.root = h.torusStep(h.root, j)
+	} else {
+		h.unboundedStep(j)
+	}
+	if len(h.nodes) > maxNodes {
+		h.rehash()
+	}
+	return 1 << j
+}
+
+// torusStep gives a torus 2^j turns later. Four copies of the torus side by side make a node whose centre
+// is the torus moved by half its size, which holds every cell the torus can affect in half its size turns.
+// Longer steps are made of two shorter ones.
+func (h *hashLife) torusStep(root *node, j int) *node {
+	if j >= root.level {
+		key := successorKey{root, j}
+		if result, ok := h.torusSteps[key]; ok {
+			return result
+		}
+		result := h.torusStep(h.torusStep(root, j-1), j-1)
+		h.torusSteps[key] = result
+		return result
+	}
+	result := h.successor(h.join(root, root, root, root), j)
+	return h.join(result.se, result.sw, result.ne, result.nw)
+}
+
+// unboundedStep advances an unbounded universe by 2^j turns. The root grows until the pattern lies in the middle
+// quarter of it, so that its centre holds every cell the pattern can reach.
+func (h *hashLife) unboundedStep(j int) {
+	for h.root.level < j+3 || h.middlePopulation(h.root) != h.root.population {
+		h.expand()
+	}
+	h.origin.X += 1 << (h.root.level - 2)
+	h.origin.Y += 1 << (h.root.level - 2)
+	h.root = h.successor(h.root, j)
+}
+
+func (h *hashLife) middlePopulation(n *node) int {
+	if n.level < 3 {
+		return -1
+	}
+	return n.nw.se.se.population + n.ne.sw.sw.population + n.sw.ne.ne.population + n.se.nw.nw.population
+}
+
+// expand doubles the size of the root, keeping it in the middle.
+func (h *hashLife) expand() {
+	root := h.root
+	e := h.emptyNode(root.level - 1)
+	h.root = h.join(
+		h.join(e, e, e, root.nw), h.join(e, e, root.ne, e),
+		h.join(e, root.sw, e, e), h.join(root.se, e, e, e),
+	)
+	h.origin.X -= 1 << (root.level - 1)
+	h.origin.Y -= 1 << (root.level - 1)
+}
+
+// rehash drops the memoised results and every node the root no longer uses.
+func (h *hashLife) rehash() {
+	old := make(map[*node]*node)
+	var intern func(n *node) *node
+	intern = func(n *node) *node {
+		if n.level == 0 {
+			if n.population == 1 {
+				return h.live
+			}
+			return h.dead
+		}
+		if result, ok := old[n]; ok {
+			return result
+		}
+		result := h.join(intern(n.nw), intern(n.ne), intern(n.sw), intern(n.se))
+		old[n] = result
+		return result
+	}
+	root := h.root
+	h.reset()
+	h.root = intern(root)
+}
+
+// AliveCells lists the live cells of the world.
+func (h *hashLife) AliveCells() []util.Cell {
+	var cells []util.Cell
+	var collect func(n *node, x, y int)
+	collect = func(n *node, x, y int) {
+		if n.population == 0 {
+			return
+		}
+		if n.level == 0 {
+			cells = append(cells, util.Cell{X: x, Y: y})
+			return
+		}
+		half := 1 << (n.level - 1)
+		collect(n.nw, x, y)
+		collect(n.ne, x+half, y)
+		collect(n.sw, x, y+half)
+		collect(n.se, x+half, y+half)
+	}
+	collect(h.root, h.origin.X, h.origin.Y)
+	return cells
+}
+
+// World gives the world of a torus as bytes. An unbounded universe gives nil, as its live cells can be
+// too far apart to hold every cell between them; worldFromCells makes its world when it is needed.
+func (h *hashLife) World(aliveCells []util.Cell) [][]byte {
+	if h.topology != util.Torus {
+		return nil
+	}
+	size := 1 << h.root.level
+	world := util.MakeWorld(size, size)
+	for _, c := range aliveCells {
+		world[c.Y][c.X] = live
+	}
+	return world
+}
+
+// maxWorldCells is the largest world worldFromCells makes.
+const maxWorldCells = 1 << 28
+
+// worldFromCells gives the smallest world holding the live cells, and the universe cell at its top left.
+func worldFromCells(aliveCells []util.Cell) ([][]byte, util.Cell, error) {
+	if len(aliveCells) == 0 {
+		return nil, util.Cell{}, nil
+	}
+	low, high := aliveCells[0], aliveCells[0]
+	for _, c := range aliveCells {
+		low.X, low.Y = minInt(low.X, c.X), minInt(low.Y, c.Y)
+		high.X, high.Y = maxInt(high.X, c.X), maxInt(high.Y, c.Y)
+	}
+	width, height := high.X-low.X+1, high.Y-low.Y+1
+	if width > maxWorldCells/height {
+		return nil, low, fmt.Errorf("the live cells span %vx%v cells, too many to make a world of", width, height)
+	}
+	world := util.MakeWorld(width, height)
+	for _, c := range aliveCells {
+		world[c.Y-low.Y][c.X-low.X] = live
+	}
+	return world, low, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+	"unsafe"
+)
+
+// turnDiff is what one turn changed: the cells that flipped, and for rules with more than two states
+// their values before and after the turn. Two-state rules leave both nil, as each cell just flips back.
+type turnDiff struct {
+	cells         []util.Cell
+	before, after []byte
+}
+
+// bytes gives roughly how much memory the diff holds.
+func (d turnDiff) bytes() int {
+	return len(d.cells)*int(unsafe.Sizeof(util.Cell{})) + len(d.before) + len(d.after)
+}
+
+// history is a ring of the diffs of the last turns, which a paused run steps back through and forward again.
+// Once the diffs take more than budget bytes the oldest ones are dropped, and a new turn drops the ones
+// that were stepped back through.
+type history struct {
+	diffs  []turnDiff
+	first  int // index of the oldest diff in diffs
+	length int // number of diffs held
+	undone int // number of the newest diffs that were stepped back through
+	size   int
+	budget int
+}
+
+// newHistory makes a history that holds up to budget megabytes, or nil for no history when budget is 0.
+func newHistory(budget int) *history {
+	if budget <= 0 {
+		return nil
+	}
+	return &history{budget: budget << 20}
+}
+
+// at gives the i-th oldest diff.
+func (h *history) at(i int) *turnDiff {
+	return &h.diffs[(h.first+i)%len(h.diffs)]
+}
+
+// record adds the diff of the turn that was just stepped.
+func (h *history) record(diff turnDiff) {
+	for ; h.undone > 0; h.undone-- {
+		h.length--
+		h.size -= h.at(h.length).bytes()
+		*h.at(h.length) = turnDiff{}
+	}
+	if h.length == len(h.diffs) {
+		// the ring is full, so it grows with the oldest diff first
+		diffs := make([]turnDiff, 2*len(h.diffs)+1)
+		for i := 0; i < h.length; i++ {
+			diffs[i] = *h.at(i)
+		}
+		h.diffs, h.first = diffs, 0
+	}
+	*h.at(h.length) = diff
+	h.length++
+	h.size += diff.bytes()
+	for h.size > h.budget && h.length > 0 {
+		h.size -= h.at(0).bytes()
+		*h.at(0) = turnDiff{}
+		h.first = (h.first + 1) % len(h.diffs)
+		h.length--
+	}
+}
+
+// back gives the diff of the last turn that has not been stepped back through, if the history still holds it.
+func (h *history) back() (turnDiff, bool) {
+	if h == nil || h.undone == h.length {
+		return turnDiff{}, false
+	}
+	h.undone++
+	return *h.at(h.length - h.undone), true
+}
+
+// forward gives the diff of the first turn that was stepped back through, if there is one.
+func (h *history) forward() (turnDiff, bool) {
+	if h == nil || h.undone == 0 {
+		return turnDiff{}, false
+	}
+	h.undone--
+	return *h.at(h.length - h.undone - 1), true
+}
@@ -2,8 +2,10 @@ package gol
 
 import (
 	"fmt"
+	"image"
+	"image/color"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -15,6 +17,10 @@ type ioChannels struct {
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
+	region   <-chan image.Rectangle
+	cells    <-chan []util.Cell
+	frames   <-chan [][][]byte
+	err      chan<- error
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -35,35 +41,172 @@ const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioOutputRegion
+	ioOutputCells
+	ioOutputFrames
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
+// The formats images can be output in, see Params.Output.
+const (
+	PgmFormat       = "pgm"
+	PbmFormat       = "pbm"
+	PngFormat       = "png"
+	RleFormat       = "rle"
+	PlaintextFormat = "cells"
+	LifeFormat      = "lif" // Life 1.06, which only lists the live cells
+)
+
+// DefaultOutDir is the directory images are output to when Params.OutDir is empty.
+const DefaultOutDir = "out"
+
+// DefaultThreshold is the grey level from which the pixels of an image are loaded as live cells
+// when Params.Threshold is 0.
+const DefaultThreshold = 128
+
+// recordingFormat is the format of the recording of a run, see Params.Record.
+const recordingFormat = "gif"
+
+// frameDelay is how long each frame of a recording is shown for, in hundredths of a second.
+const frameDelay = 10
+
+// MaxFrames bounds the frames of a recording, which are all kept in memory until it is output.
+const MaxFrames = 1000
+
+// recordingFrames gives the frames the params record: one every Record turns from the first turn,
+// and the last turn when it falls between them.
+func recordingFrames(p Params) int {
+	return (p.Turns+p.Record-1)/p.Record + 1
+}
+
+// outputFormats gives the formats the params output images in.
+func outputFormats(p Params) []string {
+	if p.Output == "" {
+		return []string{PgmFormat}
+	}
+	return strings.Split(p.Output, ",")
+}
+
+// threshold gives the grey level from which the params load pixels as live cells.
+func threshold(p Params) int {
+	if p.Threshold == 0 {
+		return DefaultThreshold
+	}
+	return p.Threshold
+}
+
+// outDir gives the directory the params output images to.
+func outDir(p Params) string {
+	if p.OutDir == "" {
+		return DefaultOutDir
+	}
+	return p.OutDir
+}
 
+// scale gives the side of the square of pixels the params draw each cell as in png and gif images.
+func scale(p Params) int {
+	if p.Scale == 0 {
+		return 1
+	}
+	return p.Scale
+}
+
+// CheckFiles checks that the params only output images in known formats, that their threshold is a grey level,
+// and that their pictures have a scale and a palette for the states of their rule and record at most MaxFrames.
+func CheckFiles(p Params) error {
+	for _, format := range outputFormats(p) {
+		switch format {
+		case PgmFormat, PbmFormat, PngFormat, RleFormat, PlaintextFormat, LifeFormat:
+		default:
+			return fmt.Errorf("unknown output format %q, expected %v, %v, %v, %v, %v or %v",
+				format, PgmFormat, PbmFormat, PngFormat, RleFormat, PlaintextFormat, LifeFormat)
+		}
+	}
+	if p.Threshold < 0 || p.Threshold > 255 {
+		return fmt.Errorf("threshold must be a grey level from 1 to 255, got %v", p.Threshold)
+	}
+	if p.Scale < 0 || p.Record < 0 {
+		return fmt.Errorf("scale and record must not be negative, got %v and %v", p.Scale, p.Record)
+	}
+	if p.Record > 0 && recordingFrames(p) > MaxFrames {
+		return fmt.Errorf("recording every %v of %v turns takes %v frames, at most %v fit in memory: record fewer turns or less often",
+			p.Record, p.Turns, recordingFrames(p), MaxFrames)
+	}
+	rule, err := util.ParseRule(p.Rule)
+	if err != nil {
+		return err
+	}
+	_, err = util.ParsePalette(p.Palette, rule)
+	return err
+}
+
+// outputName gives the name of the file an image is output to in a format.
+// PGM images keep the name without an extension, and the io goroutine picks the format of the others by theirs.
+func outputName(name, format string) string {
+	if format == PgmFormat {
+		return name
+	}
+	return name + "." + format
+}
+
+// writeImage receives an array of bytes and writes it to a file in the format of its extension.
+// It then sends the distributor nil, or the error that kept the file from being written.
+func (io *ioState) writeImage() {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
+	world := io.receiveWorld(io.params.ImageWidth, io.params.ImageHeight)
+	io.channels.err <- io.writeWorld(filename, world, nil)
+}
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
+// writeRegion is writeImage for a part of an unbounded universe.
+func (io *ioState) writeRegion() {
+	filename := <-io.channels.filename
+	region := <-io.channels.region
+	world := io.receiveWorld(region.Dx(), region.Dy())
+	io.channels.err <- io.writeWorld(filename, world, &region.Min)
+}
 
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
+// writeCells receives a list of live cells and writes it to a Life 1.06 file. The cells keep their coordinates,
+// which are those of the universe for an unbounded one.
+func (io *ioState) writeCells() {
+	filename := <-io.channels.filename
+	cells := <-io.channels.cells
+	io.channels.err <- io.writeFile(filename, filename, func(file *os.File) error {
+		return util.WriteLife106(file, cells)
+	})
+}
+
+// writeFrames receives the frames of a recording and writes them to an animated gif file.
+func (io *ioState) writeFrames() {
+	filename := <-io.channels.filename
+	frames := <-io.channels.frames
+	io.channels.err <- io.writeFile(filename, filename, func(file *os.File) error {
+		rule, palette, err := io.palette()
+		if err != nil {
+			return err
+		}
+		return util.WriteGIF(file, frames, frameDelay, scale(io.params), palette, rule)
+	})
+}
+
+// palette gives the rule of the params and the colours of its states in pictures.
+func (io *ioState) palette() (util.Rule, color.Palette, error) {
+	rule, err := util.ParseRule(io.params.Rule)
+	if err != nil {
+		return rule, nil, err
+	}
+	palette, err := util.ParsePalette(io.params.Palette, rule)
+	return rule, palette, err
+}
 
-	world := make([][]byte, io.params.ImageHeight)
+// receiveWorld receives a world of width x height cells from the distributor.
+func (io *ioState) receiveWorld(width, height int) [][]byte {
+	world := make([][]byte, height)
 	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+		world[i] = make([]byte, width)
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
 			val := <-io.channels.output
 			//if val != 0 {
 			//	fmt.Println(x, y)
@@ -71,57 +214,150 @@ func (io *ioState) writePgmImage() {
 			world[y][x] = val
 		}
 	}
+	return world
+}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
+// writeWorld writes a world to a file in the format of its extension, or to a pgm file when it has none.
+// A world that is part of an unbounded universe records the universe cell at its top left, as "# origin x y"
+// in pgm and pbm files, as "#CXRLE Pos=x,y" in RLE files and as "!origin x y" in plaintext files.
+// Png files are pictures of the world in the palette of the params, without a record of their origin.
+func (io *ioState) writeWorld(filename string, world [][]byte, origin *image.Point) error {
+	rule, err := util.ParseRule(io.params.Rule)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(filename)
+	var comments []string
+	if origin != nil {
+		comments = []string{fmt.Sprintf("origin %d %d", origin.X, origin.Y)}
+		if ext == "."+RleFormat {
+			comments = []string{fmt.Sprintf("CXRLE Pos=%d,%d", origin.X, origin.Y)}
 		}
 	}
+	path := filename
+	if ext == "" {
+		path += "." + PgmFormat
+	}
+	return io.writeFile(filename, path, func(file *os.File) error {
+		switch ext {
+		case "." + RleFormat:
+			return util.PatternOf(world, rule).WriteRLE(file, comments...)
+		case "." + PlaintextFormat:
+			return util.PatternOf(world, rule).WritePlaintext(file, comments...)
+		case "." + PngFormat:
+			_, palette, err := io.palette()
+			if err != nil {
+				return err
+			}
+			return util.WritePNG(file, world, scale(io.params), palette, rule)
+		case "." + PbmFormat:
+			if io.params.Plain {
+				return util.WriteNetpbm(file, world, util.PlainPBM, comments...)
+			}
+			return util.WriteNetpbm(file, world, util.RawPBM, comments...)
+		default:
+			if io.params.Plain {
+				return util.WriteNetpbm(file, world, util.PlainPGM, comments...)
+			}
+			return util.WriteNetpbm(file, world, util.RawPGM, comments...)
+		}
+	})
+}
+
+// writeFile creates the file at path in the output directory of the params and fills it with write.
+func (io *ioState) writeFile(filename, path string, write func(file *os.File) error) error {
+	_ = os.MkdirAll(outDir(io.params), os.ModePerm)
+
+	file, ioError := os.Create(filepath.Join(outDir(io.params), path))
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	if ioError = write(file); ioError == nil {
+		ioError = file.Sync()
+	}
+	if ioError != nil {
+		return fmt.Errorf("cannot output %v: %v", filename, ioError)
+	}
 
 	fmt.Println("File", filename, "output done!")
+	return nil
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
-
+// readImage reads the input file, and sends the distributor nil followed by its data as an array of bytes,
+// or the error that kept it from being read.
+func (io *ioState) readImage() {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
+	world, ioError := io.loadImage(filename)
+	io.channels.err <- ioError
+	if ioError != nil {
+		return
+	}
+	for _, row := range world {
+		for _, b := range row {
+			io.channels.input <- b
+		}
+	}
 
-	data, ioError := os.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
-
-	fields := strings.Fields(string(data))
+	fmt.Println("File", filename, "input done!")
+}
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+// loadImage reads the world of the input file. A filename without an extension is the name of a pgm file
+// in images, pbm, pgm and pnm files are Netpbm images, and other files are patterns read in the format of
+// their extension, see util.LoadPattern. Images and patterns of another size than the world of the params are
+// padded with dead cells or cropped to fit, placed at the offset of the params or in the middle.
+func (io *ioState) loadImage(filename string) ([][]byte, error) {
+	rule, ioError := util.ParseRule(io.params.Rule)
+	if ioError != nil {
+		return nil, ioError
 	}
-
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	var pattern util.Pattern
+	offset := io.params.Offset
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case "":
+		pattern, ioError = io.readNetpbm("images/"+filename+".pgm", rule)
+		offset = nil
+	case ".pbm", ".pgm", ".pnm":
+		pattern, ioError = io.readNetpbm(filename, rule)
+	default:
+		pattern, ioError = util.LoadPattern(filename)
 	}
-
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	if ioError != nil {
+		return nil, ioError
 	}
+	world := util.MakeWorld(io.params.ImageWidth, io.params.ImageHeight)
+	return world, pattern.Place(world, offset, rule)
+}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+// readNetpbm reads a PBM or PGM image as a pattern. Two-state rules load the grey levels from the threshold
+// of the params up as live cells and the others as dead ones, and rules with more states load each grey level
+// as the nearest state.
+func (io *ioState) readNetpbm(path string, rule util.Rule) (util.Pattern, error) {
+	file, ioError := os.Open(path)
+	if ioError != nil {
+		return util.Pattern{}, ioError
 	}
+	defer file.Close()
 
-	image := []byte(fields[4])
-
-	for _, b := range image {
-		io.channels.input <- b
+	header, cells, ioError := util.ReadNetpbm(file)
+	if ioError != nil {
+		return util.Pattern{}, fmt.Errorf("invalid image %q: %v", path, ioError)
 	}
-
-	fmt.Println("File", filename, "input done!")
+	threshold := threshold(io.params)
+	for _, row := range cells {
+		for x, grey := range row {
+			if rule.States > 2 {
+				row[x] = byte(rule.State(grey))
+			} else if int(grey) >= threshold {
+				row[x] = 1
+			} else {
+				row[x] = 0
+			}
+		}
+	}
+	return util.Pattern{Width: header.Width, Height: header.Height, Cells: cells}, nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -135,9 +371,15 @@ func startIo(p Params, c ioChannels) {
 		// Block and wait for requests from the distributor
 		switch command {
 		case ioInput:
-			io.readPgmImage()
+			io.readImage()
 		case ioOutput:
-			io.writePgmImage()
+			io.writeImage()
+		case ioOutputRegion:
+			io.writeRegion()
+		case ioOutputCells:
+			io.writeCells()
+		case ioOutputFrames:
+			io.writeFrames()
 		case ioCheckIdle:
 			io.channels.idle <- true
 		}
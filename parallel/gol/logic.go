@@ -1,24 +1,20 @@
 package gol
 
 import (
+	"math/bits"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
 const dead byte = 0
 const live byte = 255
 
-func CalculateLiveNeighbour(p Params, x, y int, immutableWorld func(int, int) byte) int {
+// CalculateLiveNeighbour counts the live neighbours of a cell. immutableWorld comes from util.MakeTopologyWorld,
+// which decides what lies beyond the edges of the world. The grid decides which cells are neighbours.
+func CalculateLiveNeighbour(p Params, grid util.Grid, x, y int, immutableWorld func(int, int) byte) int {
 	counter := 0
-	relativeDir := [8][2]int{
-		{0, 1}, {0, -1},
-		{1, 0}, {-1, 0},
-		{-1, -1}, {-1, 1},
-		{1, 1}, {1, -1},
-	}
-
-	for _, dir := range relativeDir {
-		ny := (dir[1] + p.ImageHeight + y) % p.ImageHeight
-		nx := (dir[0] + p.ImageWidth + x) % p.ImageWidth
+	for _, dir := range grid.Neighbours(x, y) {
+		ny := dir.Y + y
+		nx := dir.X + x
 
 		if immutableWorld(ny, nx) == live {
 			counter++
@@ -27,102 +23,103 @@ func CalculateLiveNeighbour(p Params, x, y int, immutableWorld func(int, int) by
 	return counter
 }
 
-func CalculateNextState(p Params, startY, endY int, immutableWorld func(int, int) byte) [][]byte {
-	// TODO : Implement a parallel version for workers
-	newWorld := util.MakeWorld(p.ImageWidth, endY-startY)
-	//fmt.Printf("%v \n", startY)
-	for y := startY; y < endY; y++ {
-		j := y - startY // adjust the column
-		for x := 0; x < p.ImageWidth; x++ {
-			counter := CalculateLiveNeighbour(p, x, y, immutableWorld)
-			if immutableWorld(y, x) == live {
-				if counter < 2 || counter > 3 {
-					newWorld[j][x] = dead
-				} else {
-					newWorld[j][x] = live
-				}
-			} else {
-				if counter == 3 {
-					newWorld[j][x] = live
-				}
-			}
-		}
-		//fmt.Printf("For loop y: %v \n", y)
-	}
-	return newWorld
+// neighbourhoodDir lists the neighbours clockwise from the north-west corner, in the bit order of util.HenselLetter.
+var neighbourhoodDir = [8][2]int{
+	{-1, -1}, {0, -1}, {1, -1}, {1, 0},
+	{1, 1}, {0, 1}, {-1, 1}, {-1, 0},
 }
 
-func CalculateAliveCells(p Params, startY, endY int, immutableWorld func(int, int) byte) []util.Cell {
-	var cells []util.Cell
-	for y := startY; y < endY; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			if immutableWorld(y, x) == live {
-				cells = append(cells, util.Cell{X: x, Y: y})
-			}
+// CalculateNeighbourhood gives the bitmask of the live neighbours of a cell, used by isotropic rules.
+func CalculateNeighbourhood(p Params, x, y int, immutableWorld func(int, int) byte) uint8 {
+	var neighbourhood uint8
+	for i, dir := range neighbourhoodDir {
+		ny := dir[1] + y
+		nx := dir[0] + x
+
+		if immutableWorld(ny, nx) == live {
+			neighbourhood |= 1 << i
 		}
 	}
-	return cells
+	return neighbourhood
 }
 
-// StateWorker worldWorker work on the same board
-// use goroutine to distribute the worker on different section and export via channel
-func StateWorker(p Params, startY, endY int, immutableWorld func(int, int) byte, worldCh chan<- [][]byte) {
-	partialWorld := CalculateNextState(p, startY, endY, immutableWorld)
-	worldCh <- partialWorld
-	//fmt.Println("finish worldCh")
+// sweepCells collects what a sweep over part of the world finds besides its next state.
+type sweepCells struct {
+	alive   []util.Cell // cells in the live state
+	flipped []util.Cell // cells whose value changed
+	values  []byte      // new values of the flipped cells
 }
 
-func AliveCellWorker(p Params, startY, endY int, immutableWorld func(int, int) byte, aliveCellCh chan<- []util.Cell) {
-	partialAliveCells := CalculateAliveCells(p, startY, endY, immutableWorld)
-	aliveCellCh <- partialAliveCells
+// reset empties the lists, keeping their room for the next turn.
+func (s *sweepCells) reset() {
+	s.alive = s.alive[:0]
+	s.flipped = s.flipped[:0]
+	s.values = s.values[:0]
 }
 
-// DelegateStateWork accumulate workload for each worker for each turn
-func DelegateStateWork(p Params, immutableWorld func(int, int) byte, worldChs []chan [][]byte, finishWorldCh chan<- [][]byte) {
-	baseWorkload := p.ImageHeight / p.Threads
-	extraWorkerThreads := p.ImageHeight % p.Threads
-
-	startY := 0
-	var finishWorld [][]byte
-
-	for t := 0; t < p.Threads; t++ {
-		workload := baseWorkload
-		if t < extraWorkerThreads {
-			workload++
-		}
-		endY := startY + workload
-		go StateWorker(p, startY, endY, immutableWorld, worldChs[t])
-		startY = endY
+// add records cell (x, y) going from value to next.
+func (s *sweepCells) add(x, y int, value, next byte) {
+	if next == live {
+		s.alive = append(s.alive, util.Cell{X: x, Y: y})
 	}
-
-	for t := 0; t < p.Threads; t++ {
-		finishWorld = append(finishWorld, <-worldChs[t]...)
+	if next != value {
+		s.flipped = append(s.flipped, util.Cell{X: x, Y: y})
+		s.values = append(s.values, next)
 	}
-	finishWorldCh <- finishWorld
 }
 
-func DelegateCellWork(p Params, immutableWorld func(int, int) byte, aliveCellChs []chan []util.Cell, finishCellsCh chan<- []util.Cell) {
-	baseWorkload := p.ImageHeight / p.Threads
-	extraWorkerThreads := p.ImageHeight % p.Threads
-
-	startY := 0
-
-	var finishCells []util.Cell
-
-	for t := 0; t < p.Threads; t++ {
-		workload := baseWorkload
-		if t < extraWorkerThreads {
-			workload++
+// CalculateNextState steps the cells startX to endX-1 of the rows startY to endY-1 of the world into newWorld,
+// and adds each of them to found in the same sweep. Cells of tiles that are not active keep their value,
+// which newWorld already holds. sums is only used by Larger than Life rules, and noise by stochastic rules
+// and by block rules for its turn.
+func CalculateNextState(p Params, rule util.Rule, noise util.Noise, startX, endX, startY, endY int, immutableWorld func(int, int) byte, newWorld [][]byte, sums *rowSums, active *activeTiles, found *sweepCells) {
+	if rule.LargerThanLife() {
+		CalculateExtendedNextState(p, rule, startX, endX, startY, endY, immutableWorld, newWorld, sums, active, found)
+		return
+	}
+	stepping := true
+	for y := startY; y < endY; y++ {
+		for x := startX; x < endX; x++ {
+			if x == startX || x%tileWidth == 0 {
+				stepping = active.isActive(x, y)
+			}
+			value := immutableWorld(y, x)
+			next := value
+			if stepping {
+				if rule.Block != nil {
+					next = rule.NextBlock(x, y, noise.Turn, immutableWorld)
+				} else if rule.Tree != nil {
+					next = rule.NextTable(x, y, immutableWorld)
+				} else if rule.Isotropic {
+					neighbourhood := CalculateNeighbourhood(p, x, y, immutableWorld)
+					next = rule.NextIsotropic(value, neighbourhood)
+					if rule.Stochastic() {
+						next = rule.Roll(value, next, bits.OnesCount8(neighbourhood), noise.Dice(x, y))
+					}
+				} else {
+					counter := CalculateLiveNeighbour(p, rule.Grid, x, y, immutableWorld)
+					next = rule.Next(value, counter)
+					if rule.Stochastic() {
+						next = rule.Roll(value, next, counter, noise.Dice(x, y))
+					}
+				}
+				newWorld[y][x] = next
+			}
+			found.add(x, y, value, next)
 		}
-		endY := startY + workload
-		go AliveCellWorker(p, startY, endY, immutableWorld, aliveCellChs[t])
-		startY = endY
 	}
+}
 
-	for t := 0; t < p.Threads; t++ {
-		finishCells = append(finishCells, <-aliveCellChs[t]...)
+// CalculateAliveCells appends the cells in the live state to cells. Dying cells of Generations rules are not alive.
+func CalculateAliveCells(p Params, startY, endY int, immutableWorld func(int, int) byte, cells []util.Cell) []util.Cell {
+	for y := startY; y < endY; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if immutableWorld(y, x) == live {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
 	}
-	finishCellsCh <- finishCells
+	return cells
 }
 
 func calculateFlippedCells(aliveCells []util.Cell, newAliveCells []util.Cell) []util.Cell {
@@ -149,3 +146,19 @@ func calculateFlippedCells(aliveCells []util.Cell, newAliveCells []util.Cell) []
 
 	return flippedCells
 }
+
+// calculateChangedCells lists the cells whose value differs between two worlds together with their new values.
+// It replaces calculateFlippedCells for Generations rules, where a cell can change without flipping.
+func calculateChangedCells(p Params, world, newWorld func(int, int) byte) ([]util.Cell, []byte) {
+	var changedCells []util.Cell
+	var values []byte
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if value := newWorld(y, x); value != world(y, x) {
+				changedCells = append(changedCells, util.Cell{X: x, Y: y})
+				values = append(values, value)
+			}
+		}
+	}
+	return changedCells, values
+}
@@ -0,0 +1,269 @@
+package gol
+
+import (
+	"sync"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// DefaultTileSize is the side of the tiles the workers share out when Params.TileSize is 0.
+const DefaultTileSize = 64
+
+// tile is a rectangle of the world a worker steps in one go.
+type tile struct {
+	startX, endX, startY, endY int
+}
+
+// tileQueue holds the tiles a worker has left to step in this turn. The worker takes them from the front,
+// and workers that have run out of their own tiles steal them from the back.
+type tileQueue struct {
+	mu    sync.Mutex
+	tiles []int
+}
+
+// take removes a tile from the front or the back of the queue.
+func (q *tileQueue) take(front bool) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tiles) == 0 {
+		return 0, false
+	}
+	if front {
+		i := q.tiles[0]
+		q.tiles = q.tiles[1:]
+		return i, true
+	}
+	i := q.tiles[len(q.tiles)-1]
+	q.tiles = q.tiles[:len(q.tiles)-1]
+	return i, true
+}
+
+// worker is one goroutine of a workerPool, with what it keeps between turns.
+type worker struct {
+	found sweepCells  // live and flipped cells of the tiles it stepped in the last turn
+	sums  rowSums     // running sums of Larger than Life rules
+	edges [2][]uint64 // rows beyond the top and bottom edges of a packed world
+}
+
+// workerPool steps the world with goroutines that live for the whole game, which wait on their turn channels
+// for the next turn. The world is cut into tiles, and every turn each worker starts on its own share of them,
+// a block of neighbouring tiles in the order of the rows. A worker that finishes early steals the tiles
+// the others have not started yet, so a busy part of the world gets spread across the workers.
+//
+// The world is double buffered: workers read the front buffer and write their tiles of the back one,
+// and the buffers are swapped once every worker is done, so a turn does not allocate another world.
+// Only the parts of the world near the cells the last turn changed are stepped, see activeTiles.
+type workerPool struct {
+	p        Params
+	rule     util.Rule
+	topology util.Topology
+	chs      *WorkerChannels
+	workers  []worker
+	tiles    []tile
+	order    []int // every tile, which the queues share out
+	queues   []tileQueue
+
+	packed          bool // whether the world is stepped as a util.BitWorld, see bitPacked
+	front           int
+	worlds          [2][][]byte
+	immutableWorlds [2]func(int, int) byte
+	voxelWorlds     [2]func(int, int, int) byte // the same worlds as cubes, for 3D rules
+	bits            [2]*util.BitWorld
+	aliveCells      [2][]util.Cell
+	active          *activeTiles // nil when the rule reaches as far as a tile, so every tile is stepped
+	noise           util.Noise   // random numbers of the turn being stepped
+}
+
+func newWorkerPool(p Params, rule util.Rule, topology util.Topology, world [][]byte, chs *WorkerChannels) *workerPool {
+	pool := &workerPool{
+		rule:     rule,
+		topology: topology,
+		chs:      chs,
+		workers:  make([]worker, p.Threads),
+		queues:   make([]tileQueue, p.Threads),
+	}
+	pool.load(p, world)
+	for t := range pool.workers {
+		go pool.work(t)
+	}
+	return pool
+}
+
+// load gives the pool a new world to step, which may differ in size from the last one.
+// The slices of a 3D world are stacked in its rows.
+func (pool *workerPool) load(p Params, world [][]byte) {
+	pool.p = p
+	pool.front = 0
+	pool.packed = bitPacked(pool.rule, pool.topology)
+	rows := p.ImageHeight * p.Depth()
+	if pool.packed {
+		pool.bits = [2]*util.BitWorld{util.PackWorld(world), util.MakeBitWorld(p.ImageWidth, p.ImageHeight)}
+	} else {
+		pool.worlds = [2][][]byte{world, util.MakeWorld(p.ImageWidth, rows)}
+		for i, w := range pool.worlds {
+			pool.immutableWorlds[i] = util.MakeTopologyWorld(w, pool.topology)
+			if pool.rule.Cubic {
+				pool.voxelWorlds[i] = util.MakeVoxelWorld(w, p.Depth(), pool.topology)
+			}
+		}
+	}
+
+	// the back buffer does not hold the world yet, so every tile is stepped in the first turn,
+	// and cells of stochastic and block rules can change whether or not their neighbours did
+	pool.active = nil
+	if pool.rule.Range < tileHeight && !pool.rule.Stochastic() && pool.rule.Block == nil && !pool.rule.Cubic {
+		pool.active = newActiveTiles(p.ImageWidth, p.ImageHeight)
+	}
+
+	width, height := p.TileSize, p.TileSize
+	if width == 0 {
+		width, height = DefaultTileSize, DefaultTileSize
+	}
+	if pool.packed {
+		// workers must not share the words of a packed row
+		width = util.Words(width) * 64
+	}
+	pool.tiles = pool.tiles[:0]
+	for y := 0; y < rows; y += height {
+		for x := 0; x < p.ImageWidth; x += width {
+			pool.tiles = append(pool.tiles, tile{
+				startX: x, endX: minInt(x+width, p.ImageWidth),
+				startY: y, endY: minInt(y+height, rows),
+			})
+		}
+	}
+	pool.order = make([]int, len(pool.tiles))
+	for i := range pool.order {
+		pool.order[i] = i
+	}
+
+	for t := range pool.workers {
+		pool.workers[t].edges = [2][]uint64{make([]uint64, util.Words(p.ImageWidth)), make([]uint64, util.Words(p.ImageWidth))}
+	}
+}
+
+// nextTile gives the next tile worker t steps in this turn, stolen from another worker once its own run out.
+func (pool *workerPool) nextTile(t int) (int, bool) {
+	if i, ok := pool.queues[t].take(true); ok {
+		return i, true
+	}
+	for k := 1; k < len(pool.queues); k++ {
+		if i, ok := pool.queues[(t+k)%len(pool.queues)].take(false); ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// work steps tiles for worker t every time it is told to, until the turn channel is closed.
+func (pool *workerPool) work(t int) {
+	w := &pool.workers[t]
+	for range pool.chs.TurnChannels[t] {
+		back := 1 - pool.front
+		w.found.reset()
+		for i, ok := pool.nextTile(t); ok; i, ok = pool.nextTile(t) {
+			tl := pool.tiles[i]
+			if pool.packed {
+				CalculateBitNextState(pool.p, pool.rule, pool.topology, tl.startX, tl.endX, tl.startY, tl.endY,
+					pool.bits[pool.front], pool.bits[back].Rows, w.edges, pool.active, &w.found)
+			} else if pool.rule.Cubic {
+				CalculateVoxelNextState(pool.p, pool.rule, tl.startX, tl.endX, tl.startY, tl.endY,
+					pool.voxelWorlds[pool.front], pool.worlds[back], &w.found)
+			} else {
+				CalculateNextState(pool.p, pool.rule, pool.noise, tl.startX, tl.endX, tl.startY, tl.endY,
+					pool.immutableWorlds[pool.front], pool.worlds[back], &w.sums, pool.active, &w.found)
+			}
+		}
+		pool.chs.DoneChannel <- true
+	}
+}
+
+// step runs one turn on every worker and swaps the buffers. It gives the live cells of the new world,
+// which are only valid until the turn after next, and the cells the turn changed, with their new values
+// for Generations rules. noise gives the random numbers of stochastic rules for this turn.
+func (pool *workerPool) step(noise util.Noise) ([]util.Cell, []util.Cell, []byte) {
+	pool.noise = noise
+	for t := range pool.queues {
+		pool.queues[t].tiles = pool.order[t*len(pool.order)/len(pool.queues) : (t+1)*len(pool.order)/len(pool.queues)]
+	}
+	for t := range pool.workers {
+		pool.chs.TurnChannels[t] <- true
+	}
+	for range pool.workers {
+		<-pool.chs.DoneChannel
+	}
+	pool.front = 1 - pool.front
+
+	aliveCells := pool.aliveCells[pool.front][:0]
+	var flipped []util.Cell
+	var values []byte
+	for _, w := range pool.workers {
+		aliveCells = append(aliveCells, w.found.alive...)
+		flipped = append(flipped, w.found.flipped...)
+		if pool.rule.States > 2 {
+			values = append(values, w.found.values...)
+		}
+	}
+	pool.aliveCells[pool.front] = aliveCells
+
+	if pool.active != nil {
+		pool.active.track(flipped, pool.rule.Range, pool.topology)
+	}
+	return aliveCells, flipped, values
+}
+
+// world gives the world after the last turn, or nil when it is packed.
+func (pool *workerPool) world() [][]byte {
+	if pool.packed {
+		return nil
+	}
+	return pool.worlds[pool.front]
+}
+
+// packedWorld gives the packed world after the last turn, or nil when it is not packed.
+func (pool *workerPool) packedWorld() *util.BitWorld {
+	return pool.bits[pool.front]
+}
+
+// previousValues gives the values the cells had before the last turn, which the back buffer still holds.
+// It is only used by rules with more than two states, whose worlds are never packed.
+func (pool *workerPool) previousValues(cells []util.Cell) []byte {
+	world := pool.worlds[1-pool.front]
+	values := make([]byte, len(cells))
+	for i, cell := range cells {
+		values[i] = world[cell.Y][cell.X]
+	}
+	return values
+}
+
+// replay sets the cells of the world to values, or flips them when values is nil, to step back or forward
+// through the history. The cells lie in the universe, whose cell at the top left of the world is origin.
+// It loads the changed world and gives its live cells, also in the universe.
+func (pool *workerPool) replay(cells []util.Cell, values []byte, origin util.Cell) []util.Cell {
+	world := pool.world()
+	if world == nil {
+		world = pool.packedWorld().Unpack()
+	}
+	for i, cell := range cells {
+		x, y := cell.X-origin.X, cell.Y-origin.Y
+		if values != nil {
+			world[y][x] = values[i]
+		} else if world[y][x] == live {
+			world[y][x] = dead
+		} else {
+			world[y][x] = live
+		}
+	}
+	pool.load(pool.p, world)
+	aliveCells := CalculateAliveCells(pool.p, 0, len(world), util.MakeImmutableWorld(world), nil)
+	if pool.topology == util.Unbounded {
+		aliveCells = util.Translate(aliveCells, origin)
+	}
+	return aliveCells
+}
+
+// stop ends the goroutines of the pool.
+func (pool *workerPool) stop() {
+	for _, ch := range pool.chs.TurnChannels {
+		close(ch)
+	}
+}
@@ -0,0 +1,71 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// recorder keeps the frames of the animated gif of a run, which are its world every few turns. It follows the
+// world through the CellsFlipped events the distributor sends, so recording costs a copy of the world a frame
+// and nothing in between, up to MaxFrames frames, see CheckFiles. An unbounded universe is recorded in the window
+// of the image it started from.
+type recorder struct {
+	every  int      // turns between frames
+	next   int      // turn of the next frame
+	last   int      // turn of the last frame
+	world  [][]byte // world of the latest turn seen
+	frames [][][]byte
+}
+
+// newRecorder starts recording a run of the params from an empty world, which the first CellsFlipped
+// of the run fills in. It gives nil when the params record nothing.
+func newRecorder(p Params) *recorder {
+	if p.Record <= 0 {
+		return nil
+	}
+	return &recorder{
+		every: p.Record,
+		last:  -1,
+		world: util.MakeWorld(p.ImageWidth, p.ImageHeight*p.Depth()),
+	}
+}
+
+// see applies a CellsFlipped event to the world, and keeps the world as a frame when the turn of the event
+// reaches the turn of the next frame. Turns stepped back through the history are not recorded again.
+// A nil recorder records nothing.
+func (r *recorder) see(flipped CellsFlipped) {
+	if r == nil {
+		return
+	}
+	for i, cell := range flipped.Cells {
+		if cell.Y < 0 || cell.Y >= len(r.world) || cell.X < 0 || cell.X >= len(r.world[cell.Y]) {
+			continue
+		}
+		if flipped.Values != nil {
+			r.world[cell.Y][cell.X] = flipped.Values[i]
+		} else {
+			r.world[cell.Y][cell.X] ^= live
+		}
+	}
+	if flipped.CompletedTurns >= r.next && len(r.frames) < MaxFrames {
+		r.frames = append(r.frames, r.copyWorld())
+		r.last = flipped.CompletedTurns
+		// HashLife may jump past several frames at once
+		r.next = (flipped.CompletedTurns/r.every + 1) * r.every
+	}
+}
+
+// copyWorld gives a copy of the world as a frame.
+func (r *recorder) copyWorld() [][]byte {
+	frame := util.MakeWorld(len(r.world[0]), len(r.world))
+	for y := range frame {
+		copy(frame[y], r.world[y])
+	}
+	return frame
+}
+
+// recording gives the frames so far, ending with the world of turn when it was not kept yet.
+// That last frame is not kept, so saving the recording many times does not use up the frames.
+func (r *recorder) recording(turn int) [][][]byte {
+	if r.last >= turn {
+		return r.frames
+	}
+	return append(r.frames[:len(r.frames):len(r.frames)], r.copyWorld())
+}
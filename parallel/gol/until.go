@@ -0,0 +1,76 @@
+package gol
+
+import (
+	"image"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// speedWindow is how long the turns are counted over to compare the speed of a run with Params.MinSpeed.
+const speedWindow = 2 * time.Second
+
+// stopConditions checks the conditions of the params that finish a run before its last turn.
+// They are checked after each turn is stepped, or after each jump of the HashLife engine.
+type stopConditions struct {
+	p     Params
+	start time.Time
+
+	box      image.Rectangle // bounding box of the live cells
+	boxSince int             // turn the live cells took that bounding box in
+
+	speedTurn  int // first turn of the current window of speedWindow
+	speedStart time.Time
+}
+
+func newStopConditions(p Params, turn int) *stopConditions {
+	now := time.Now()
+	return &stopConditions{p: p, start: now, boxSince: turn, speedTurn: turn, speedStart: now}
+}
+
+// resume starts counting the speed again after a pause, which does not make the run slow.
+func (s *stopConditions) resume(turn int) {
+	s.speedTurn, s.speedStart = turn, time.Now()
+}
+
+// check gives the first condition the world of turn meets, or TurnsCompleted while it meets none.
+func (s *stopConditions) check(turn int, aliveCells []util.Cell) StopReason {
+	p := s.p
+	if p.StopOnExtinction && len(aliveCells) == 0 {
+		return Extinct
+	}
+	if p.MinPopulation > 0 && len(aliveCells) < p.MinPopulation {
+		return PopulationBelow
+	}
+	if p.MaxPopulation > 0 && len(aliveCells) > p.MaxPopulation {
+		return PopulationAbove
+	}
+	if p.StableBox > 0 {
+		if box := cellsBoundingBox(aliveCells); box != s.box {
+			s.box, s.boxSince = box, turn
+		} else if turn-s.boxSince >= p.StableBox {
+			return BoxUnchanged
+		}
+	}
+	if p.TimeLimit > 0 || p.MinSpeed > 0 {
+		now := time.Now()
+		if p.TimeLimit > 0 && now.Sub(s.start) > p.TimeLimit {
+			return TimeLimitReached
+		}
+		if elapsed := now.Sub(s.speedStart); p.MinSpeed > 0 && elapsed >= speedWindow {
+			if float64(turn-s.speedTurn)/elapsed.Seconds() < p.MinSpeed {
+				return TooSlow
+			}
+			s.resume(turn)
+		}
+	}
+	return TurnsCompleted
+}
+
+// cellsBoundingBox gives the smallest rectangle holding the cells, which is empty when there are none.
+func cellsBoundingBox(cells []util.Cell) image.Rectangle {
+	var box image.Rectangle
+	for _, c := range cells {
+		box = box.Union(image.Rect(c.X, c.Y, c.X+1, c.Y+1))
+	}
+	return box
+}
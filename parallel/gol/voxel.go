@@ -0,0 +1,42 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// Depth gives the number of slices of the world, which is 1 unless a 3D rule runs on it.
+func (p Params) Depth() int {
+	if p.ImageDepth == 0 {
+		return 1
+	}
+	return p.ImageDepth
+}
+
+// CalculateLiveVoxels counts the live cubes among the 26 around cube (x, y, z).
+// voxelWorld comes from util.MakeVoxelWorld, which decides what lies beyond the faces of the world.
+func CalculateLiveVoxels(x, y, z int, voxelWorld func(int, int, int) byte) int {
+	counter := 0
+	for dz := -1; dz <= 1; dz++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0 || dz != 0) && voxelWorld(z+dz, y+dy, x+dx) == live {
+					counter++
+				}
+			}
+		}
+	}
+	return counter
+}
+
+// CalculateVoxelNextState is CalculateNextState for 3D rules. The slices of the world are stacked in its rows,
+// so row y holds row y%ImageHeight of slice y/ImageHeight, and the strips of the workers cut through
+// the slices like they cut through the rows of a 2D world.
+func CalculateVoxelNextState(p Params, rule util.Rule, startX, endX, startY, endY int, voxelWorld func(int, int, int) byte, newWorld [][]byte, found *sweepCells) {
+	for y := startY; y < endY; y++ {
+		z, sy := y/p.ImageHeight, y%p.ImageHeight
+		for x := startX; x < endX; x++ {
+			value := voxelWorld(z, sy, x)
+			next := rule.Next(value, CalculateLiveVoxels(x, sy, z, voxelWorld))
+			newWorld[y][x] = next
+			found.add(x, y, value, next)
+		}
+	}
+}
@@ -4,29 +4,30 @@ import (
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// WorkerChannels start the workers of a workerPool on a turn, and tell the pool when each of them is done.
 type WorkerChannels struct {
-	StateWorkerChannels   []chan [][]byte
-	CellWorkerChannels    []chan []util.Cell
-	NextStateChannel      chan [][]byte
-	NextAliveCellsChannel chan []util.Cell
+	TurnChannels []chan bool
+	DoneChannel  chan bool
 }
 
 func (w *WorkerChannels) InitialiseChannels(p Params) {
-	w.StateWorkerChannels = util.MakeStateWorkerChannels(p.Threads)
-	w.CellWorkerChannels = util.MakeCellWorkerChannels(p.Threads)
-	w.NextStateChannel = util.MakeNextStateChannel()
-	w.NextAliveCellsChannel = util.MakeNextAliveCellChannel()
+	w.TurnChannels = util.MakeTurnChannels(p.Threads)
+	w.DoneChannel = util.MakeBoolChannel()
 }
 
 type KeyPressChannels struct {
-	SaveChannel  chan bool
-	QuitChannel  chan bool
-	PauseChannel chan bool
-	Done         chan bool
+	SaveChannel    chan bool
+	QuitChannel    chan bool
+	PauseChannel   chan bool
+	BackChannel    chan bool
+	ForwardChannel chan bool
+	Done           chan bool
 }
 
 func (k *KeyPressChannels) InitialiseChannels() {
 	k.SaveChannel = util.MakeBoolChannel()
 	k.QuitChannel = util.MakeBoolChannel()
 	k.PauseChannel = util.MakeBoolChannel()
+	k.BackChannel = util.MakeBoolChannel()
+	k.ForwardChannel = util.MakeBoolChannel()
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"testing"
 	"uk.ac.bris.cs/gameoflife/gol"
-	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads.
@@ -26,18 +25,22 @@ func TestGol(t *testing.T) {
 				p.Threads = threads
 				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
 				t.Run(testName, func(t *testing.T) {
-					events := make(chan gol.Event)
-					go gol.Run(p, events, nil)
-					var cells []util.Cell
-					for event := range events {
-						switch e := event.(type) {
-						case gol.FinalTurnComplete:
-							cells = e.Alive
-						}
-					}
-					assertEqualBoard(t, cells, expectedAlive, p)
+					assertEqualBoard(t, finalAlive(p), expectedAlive, p)
 				})
 			}
 		}
 	}
 }
+
+// TestTileSize tests the 512x512 image on 100 turns with tiles of several sides, including ones
+// that do not divide the image.
+func TestTileSize(t *testing.T) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100, Threads: 8}
+	expectedAlive := readAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight)
+	for _, tileSize := range []int{1, 7, 64, 100, 512} {
+		p.TileSize = tileSize
+		t.Run(fmt.Sprintf("%d", tileSize), func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+		})
+	}
+}
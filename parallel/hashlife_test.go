@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHashLife tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using the HashLife engine.
+func TestHashLife(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			p.Threads = 1
+			p.Engine = gol.HashLifeEngine
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestHashLifeJump tests that the glider of the 16x16 image travels 2.5 billion cells in the default number of turns
+// of an unbounded universe.
+func TestHashLifeJump(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10000000000, Threads: 1,
+		Topology: "unbounded", Engine: gol.HashLifeEngine}
+	alive := finalAlive(p)
+	if len(alive) != 5 {
+		t.Fatalf("ERROR: Expected the 5 cells of the glider, got %v", len(alive))
+	}
+	for _, cell := range alive {
+		if cell.X < 2500000000 || cell.X > 2500000016 || cell.Y < 2500000000 || cell.Y > 2500000016 {
+			t.Errorf("ERROR: Expected the glider 2500000000 cells down and right, found a cell at %v", cell)
+		}
+	}
+}
+
+// TestHashLifeApart tests that once the live cells of an unbounded universe lie too far apart for an image,
+// the final world is still output as Life 1.06 coordinates, and the image sends an IoError instead.
+func TestHashLifeApart(t *testing.T) {
+	// two gliders flying away from each other
+	path := filepath.Join(t.TempDir(), "gliders.cells")
+	util.Check(os.WriteFile(path, []byte("OOO\nO\n.O\n\n\n\n......O\n.......O\n.....OOO\n"), 0644))
+	p := gol.Params{ImageWidth: 8, ImageHeight: 9, Turns: 1 << 20, Threads: 1, Input: path,
+		Topology: "unbounded", Engine: gol.HashLifeEngine, Output: "pgm,lif"}
+	emptyOutFolder()
+	run := runEvents(p)
+	if len(run.final.Alive) != 10 {
+		t.Fatalf("ERROR: Expected the 10 cells of the gliders, got %v", len(run.final.Alive))
+	}
+	if len(run.errs) != 1 || len(run.filenames) != 1 || filepath.Ext(run.filenames[0]) != ".lif" {
+		t.Fatalf("ERROR: Expected an IoError for the pgm image and the lif file output, got %v and %v", run.errs, run.filenames)
+	}
+	assertEqualBoard(t, readLife106(t, "out/"+run.filenames[0]), run.final.Alive, p)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestInputSize tests that the size of the world is read from the header of images and from patterns.
+func TestInputSize(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"glider.rle":    "#N Glider\nx = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n",
+		"blinker.cells": "!Name: Blinker\nOOO\n",
+		"glider.lif":    "#Life 1.06\n0 -1\n1 0\n-1 1\n0 1\n1 1\n",
+	}
+	expected := map[string][2]int{"glider.rle": {3, 3}, "blinker.cells": {3, 1}, "glider.lif": {3, 3}}
+	for name, contents := range files {
+		util.Check(os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+	for _, size := range []int{16, 64, 512} {
+		expected[fmt.Sprintf("images/%vx%v.pgm", size, size)] = [2]int{size, size}
+	}
+	for name, size := range expected {
+		path := name
+		if _, ok := files[name]; ok {
+			path = filepath.Join(dir, name)
+		}
+		width, height, err := util.InputSize(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if width != size[0] || height != size[1] {
+			t.Errorf("ERROR: Expected %v to be %vx%v, got %vx%v", name, size[0], size[1], width, height)
+		}
+	}
+}
+
+// TestInputFit tests that an image of another size than the world is padded with dead cells or cropped to fit,
+// in the middle of the world or at the offset of the params, and that images are output to OutDir.
+func TestInputFit(t *testing.T) {
+	dir := t.TempDir()
+	glider := []util.Cell{{X: 6, Y: 5}, {X: 7, Y: 6}, {X: 5, Y: 7}, {X: 6, Y: 7}, {X: 7, Y: 7}}
+	path := filepath.Join(dir, "glider.pgm")
+	// the cell in the bottom right corner is cropped off
+	util.Check(os.WriteFile(path, netpbmImage(util.RawPGM, 255, 255, 0, append(glider, util.Cell{X: 15, Y: 15})), 0644))
+	outDir := filepath.Join(dir, "images")
+
+	for _, test := range []struct {
+		name     string
+		offset   *util.Cell
+		expected []util.Cell
+	}{
+		{"middle", nil, []util.Cell{{X: 8, Y: 3}, {X: 9, Y: 4}, {X: 7, Y: 5}, {X: 8, Y: 5}, {X: 9, Y: 5}}},
+		{"offset", &util.Cell{X: -4, Y: 0}, []util.Cell{{X: 2, Y: 5}, {X: 3, Y: 6}, {X: 1, Y: 7}, {X: 2, Y: 7}, {X: 3, Y: 7}}},
+	} {
+		p := gol.Params{ImageWidth: 20, ImageHeight: 12, Threads: 1, Input: path, Offset: test.offset, OutDir: outDir}
+		t.Run(test.name, func(t *testing.T) {
+			run := runEvents(p)
+			if len(run.errs) > 0 {
+				t.Fatal(run.errs)
+			}
+			assertEqualBoard(t, run.final.Alive, test.expected, p)
+			if len(run.filenames) != 1 {
+				t.Fatalf("ERROR: Expected one image, got %v", run.filenames)
+			}
+			if _, err := os.Stat(filepath.Join(outDir, run.filenames[0]+".pgm")); err != nil {
+				t.Errorf("ERROR: Expected the image in %v: %v", outDir, err)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // TestKeyboard tests key presses and events
@@ -211,3 +212,127 @@ func testKeyboardPQ(t *testing.T) {
 
 	tester.Loop()
 }
+
+// TestRewind tests that b steps a paused run back one turn and f forward again, with events that rebuild the worlds
+// of those turns, and that the run carries on from the turn it was stepped back to.
+func TestRewind(t *testing.T) {
+	for _, rule := range []string{"B3/S23", "345/2/4"} {
+		t.Run(rule, func(t *testing.T) {
+			testRewind(t, rule)
+		})
+	}
+}
+
+func testRewind(t *testing.T, rule string) {
+	params := gol.Params{
+		Turns:       100000000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		Rule:        rule,
+		History:     1,
+	}
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	go gol.Run(params, events, keyPresses)
+
+	// the world is rebuilt from the events, and kept for every turn
+	world := make(map[util.Cell]byte)
+	worlds := make(map[int]map[util.Cell]byte)
+	keep := func(turn int) {
+		kept := make(map[util.Cell]byte, len(world))
+		for cell, value := range world {
+			kept[cell] = value
+		}
+		worlds[turn] = kept
+	}
+	check := func(turn int) {
+		kept, ok := worlds[turn]
+		if !ok {
+			return
+		}
+		if len(kept) != len(world) {
+			t.Errorf("ERROR: Expected %v cells that are not dead in turn %v, got %v", len(kept), turn, len(world))
+			return
+		}
+		for cell, value := range kept {
+			if world[cell] != value {
+				t.Errorf("ERROR: Expected cell %v to be %v in turn %v, got %v", cell, value, turn, world[cell])
+				return
+			}
+		}
+	}
+
+	// after pausing, each key is pressed once the turn before it is complete, and gives the next turn
+	pausedAt := 0
+	var keys []rune
+	var expectedTurns []int
+	timeout := time.After(30 * time.Second)
+	for {
+		var event gol.Event
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if len(expectedTurns) > 0 {
+					t.Errorf("ERROR: Expected turns %v before the run ended", expectedTurns)
+				}
+				return
+			}
+			event = e
+		case <-timeout:
+			t.Fatalf("ERROR: No events for 30 seconds, expected turns %v", expectedTurns)
+		}
+		switch e := event.(type) {
+		case gol.CellsFlipped:
+			for i, cell := range e.Cells {
+				value := byte(255)
+				if e.Values != nil {
+					value = e.Values[i]
+				} else if world[cell] != 0 {
+					value = 0
+				}
+				if value == 0 {
+					delete(world, cell)
+				} else {
+					world[cell] = value
+				}
+			}
+		case gol.TurnComplete:
+			if pausedAt == 0 {
+				keep(e.CompletedTurns)
+				if e.CompletedTurns == 10 {
+					keyPresses <- 'p'
+				}
+				break
+			}
+			if len(expectedTurns) == 0 {
+				// the run may complete a few more turns before it quits
+				break
+			}
+			if e.CompletedTurns != expectedTurns[0] {
+				t.Fatalf("ERROR: Expected turns %v, got %v", expectedTurns, e.CompletedTurns)
+			}
+			check(e.CompletedTurns)
+			expectedTurns = expectedTurns[1:]
+			if len(keys) > 0 {
+				keyPresses <- keys[0]
+				keys = keys[1:]
+			}
+			if len(expectedTurns) == 0 {
+				keyPresses <- 'q'
+			}
+		case gol.StateChange:
+			if e.NewState == gol.Paused && pausedAt == 0 {
+				pausedAt = e.CompletedTurns
+				if pausedAt < 3 {
+					t.Fatalf("ERROR: Expected the run to pause after turn 10, got %v", pausedAt)
+				}
+				// back three turns and forward one, then the run carries on for two turns
+				keys = []rune{'b', 'b', 'f', 'p'}
+				expectedTurns = []int{pausedAt - 1, pausedAt - 2, pausedAt - 3, pausedAt - 2, pausedAt - 1, pausedAt}
+				keyPresses <- 'b'
+			}
+		}
+	}
+}
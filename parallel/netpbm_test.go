@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// netpbmImage makes a 16x16 Netpbm image of the magic number, with comments in its header,
+// whose pixels have the grey level live for the cells given and dead for the others.
+func netpbmImage(magic string, maxval, live, dead int, cells []util.Cell) []byte {
+	greys := make([][]int, 16)
+	for y := range greys {
+		greys[y] = make([]int, 16)
+		for x := range greys[y] {
+			greys[y][x] = dead
+		}
+	}
+	for _, c := range cells {
+		greys[c.Y][c.X] = live
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%v\n# a comment\n16 # another one\n16\n", magic)
+	if magic == util.PlainPGM || magic == util.RawPGM {
+		fmt.Fprintf(&b, "%v\n", maxval)
+	}
+	for _, row := range greys {
+		switch magic {
+		case util.PlainPBM:
+			// without whitespace between the pixels
+			for _, grey := range row {
+				fmt.Fprint(&b, 1-grey)
+			}
+			b.WriteByte('\n')
+		case util.PlainPGM:
+			for _, grey := range row {
+				fmt.Fprintf(&b, "%v ", grey)
+			}
+			b.WriteByte('\n')
+		case util.RawPBM:
+			bits := make([]byte, 2)
+			for x, grey := range row {
+				bits[x/8] |= byte(1-grey) << (7 - x%8)
+			}
+			b.Write(bits)
+		case util.RawPGM:
+			for _, grey := range row {
+				if maxval > 255 {
+					b.WriteByte(byte(grey >> 8))
+				}
+				b.WriteByte(byte(grey))
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// TestNetpbm tests that plain and raw bitmaps and greymaps are read with their comments and maxval,
+// with the threshold picking the live cells, and that the final world is output as each of them.
+func TestNetpbm(t *testing.T) {
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name      string
+		image     []byte
+		threshold int
+	}{
+		{"plain.pbm", netpbmImage(util.PlainPBM, 1, 1, 0, glider), 0},
+		{"raw.pbm", netpbmImage(util.RawPBM, 1, 1, 0, glider), 0},
+		{"plain.pgm", netpbmImage(util.PlainPGM, 15, 9, 7, glider), 0},
+		// dead pixels that look like whitespace
+		{"raw.pgm", netpbmImage(util.RawPGM, 255, 100, '\n', glider), 50},
+		{"wide.pgm", netpbmImage(util.RawPGM, 1000, 600, 400, glider), 0},
+	} {
+		path := filepath.Join(dir, test.name)
+		util.Check(os.WriteFile(path, test.image, 0644))
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 1, Input: path, Threshold: test.threshold}
+		t.Run(test.name, func(t *testing.T) {
+			run := runEvents(p)
+			if len(run.errs) > 0 {
+				t.Fatal(run.errs)
+			}
+			assertEqualBoard(t, run.final.Alive, glider, p)
+		})
+	}
+
+	for _, plain := range []bool{false, true} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, Output: "pgm,pbm", Plain: plain}
+		t.Run(fmt.Sprintf("output-plain-%v", plain), func(t *testing.T) {
+			emptyOutFolder()
+			run := runEvents(p)
+			if len(run.errs) > 0 || len(run.filenames) != 2 {
+				t.Fatalf("ERROR: Expected a pgm and a pbm image, got %v and errors %v", run.filenames, run.errs)
+			}
+			for i, path := range []string{"out/" + run.filenames[0] + ".pgm", "out/" + run.filenames[1]} {
+				file, err := os.Open(path)
+				util.Check(err)
+				header, world, err := util.ReadNetpbm(file)
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				magic := []string{util.RawPGM, util.RawPBM}[i]
+				if plain {
+					magic = []string{util.PlainPGM, util.PlainPBM}[i]
+				}
+				if header.Magic != magic {
+					t.Errorf("ERROR: Expected %v to be a %v image, got %v", path, magic, header.Magic)
+				}
+				assertEqualBoard(t, util.LiveCells(world, util.Cell{}), run.final.Alive, p)
+			}
+		})
+	}
+}
+
+// TestNetpbmErrors tests that an input that cannot be read finishes the run with an IoError
+// instead of a panic, and that a run goes on when an image cannot be output.
+func TestNetpbmErrors(t *testing.T) {
+	dir := t.TempDir()
+	image := netpbmImage(util.RawPGM, 255, 255, 0, nil)
+	for name, contents := range map[string][]byte{
+		"truncated.pgm": image[:len(image)-1],
+		"magic.pgm":     append([]byte("P6"), image[2:]...),
+		"maxval.pgm":    bytes.Replace(image, []byte("\n255\n"), []byte("\n70000\n"), 1),
+		"above.pgm":     netpbmImage(util.PlainPGM, 15, 16, 0, []util.Cell{{X: 3, Y: 3}}),
+		"pixel.pbm":     bytes.Replace(netpbmImage(util.PlainPBM, 1, 1, 0, nil), []byte("11"), []byte("12"), 1),
+	} {
+		path := filepath.Join(dir, name)
+		util.Check(os.WriteFile(path, contents, 0644))
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 1, Input: path}
+		t.Run(name, func(t *testing.T) {
+			run := runEvents(p)
+			if len(run.errs) != 1 || len(run.filenames) != 0 {
+				t.Errorf("ERROR: Expected one IoError and no output, got %v and %v", run.errs, run.filenames)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 1, Input: filepath.Join(dir, "missing.pgm")}
+		if errs := runEvents(p).errs; len(errs) != 1 {
+			t.Errorf("ERROR: Expected one IoError, got %v", errs)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		emptyOutFolder()
+		// a directory where the image should go cannot be written to
+		util.Check(os.MkdirAll("out/16x16x100.pbm", os.ModePerm))
+		defer os.RemoveAll("out/16x16x100.pbm")
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 1, Output: "pbm,pgm"}
+		run := runEvents(p)
+		if len(run.errs) != 1 || len(run.filenames) != 1 || run.filenames[0] != "16x16x100" {
+			t.Errorf("ERROR: Expected an IoError for the pbm image and the pgm image output, got %v and %v", run.errs, run.filenames)
+		}
+		assertEqualBoard(t, run.final.Alive, readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+	})
+}
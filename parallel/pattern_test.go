@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// readLife106 reads the coordinates of a Life 1.06 file as they are, which util.LoadPattern moves to the
+// top left of the pattern.
+func readLife106(t *testing.T, path string) []util.Cell {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || scanner.Text() != "#Life 1.06" {
+		t.Fatalf("ERROR: %v does not start with #Life 1.06", path)
+	}
+	var cells []util.Cell
+	for scanner.Scan() {
+		var c util.Cell
+		if _, err := fmt.Sscan(scanner.Text(), &c.X, &c.Y); err != nil {
+			t.Fatalf("ERROR: %v has an invalid cell %q", path, scanner.Text())
+		}
+		cells = append(cells, c)
+	}
+	return cells
+}
+
+// TestPatternFormats tests that the final world is output as a plaintext file and as Life 1.06 coordinates,
+// which list the alive cells of FinalTurnComplete in the coordinates of the universe when it is unbounded,
+// and that both are read back as the same pattern.
+func TestPatternFormats(t *testing.T) {
+	for _, topology := range []string{"torus", "unbounded"} {
+		for _, size := range []int{16, 64} {
+			p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: 100, Threads: 4, Topology: topology, Output: "cells,lif"}
+			t.Run(fmt.Sprintf("%dx%d-%v", size, size, topology), func(t *testing.T) {
+				emptyOutFolder()
+				run := runEvents(p)
+				filenames := run.filenames
+				if len(filenames) != 2 || filepath.Ext(filenames[0]) != ".cells" || filepath.Ext(filenames[1]) != ".lif" {
+					t.Fatalf("ERROR: Expected the final image as .cells and .lif, got %v", filenames)
+				}
+				listed := readLife106(t, "out/"+filenames[1])
+				assertEqualBoard(t, listed, run.final.Alive, p)
+
+				rule, err := util.ParseRule("")
+				util.Check(err)
+				var worlds []string
+				for _, filename := range filenames {
+					pattern, err := util.LoadPattern("out/" + filename)
+					if err != nil {
+						t.Fatal(err)
+					}
+					world := util.MakeWorld(pattern.Width, pattern.Height)
+					util.Check(pattern.Place(world, nil, rule))
+					cropped, _ := util.CropWorld(world)
+					worlds = append(worlds, fmt.Sprint(cropped))
+				}
+				if worlds[0] != worlds[1] {
+					t.Errorf("ERROR: The .cells and .lif files hold different patterns")
+				}
+			})
+		}
+	}
+}
+
+// TestPatternInput tests that plaintext and Life 1.06 patterns are placed in the world like RLE patterns,
+// and that Life 1.06 cells too far apart for a netpbm image are refused.
+func TestPatternInput(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"glider.cells": "!Name: Glider\n.O\n..O\nOOO\n",
+		"glider.lif":   "#Life 1.06\n0 -1\n1 0\n-1 1\n0 1\n1 1\n",
+	}
+	expected := []util.Cell{{X: 3, Y: 1}, {X: 4, Y: 2}, {X: 2, Y: 3}, {X: 3, Y: 3}, {X: 4, Y: 3}}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		util.Check(os.WriteFile(path, []byte(contents), 0644))
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 1, Input: path, Offset: &util.Cell{X: 2, Y: 1}}
+		t.Run(name, func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), expected, p)
+		})
+	}
+
+	for _, lif := range []string{
+		"#Life 1.06\n-9223372036854775808 0\n9223372036854775807 0\n",
+		"#Life 1.06\n0 0\n1073741824 0\n",
+		"#Life 1.06\n0 0\n65536 65536\n",
+	} {
+		if _, err := util.ReadLife106(strings.NewReader(lif)); err == nil {
+			t.Errorf("ERROR: Expected pattern %q to be refused", lif)
+		}
+	}
+}
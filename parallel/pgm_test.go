@@ -28,10 +28,7 @@ func TestPgm(t *testing.T) {
 				p.Threads = threads
 				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
 				t.Run(testName, func(t *testing.T) {
-					events := make(chan gol.Event)
-					go gol.Run(p, events, nil)
-					for range events {
-					}
+					runEvents(p)
 					cellsFromImage := readAliveCells(
 						"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
 						p.ImageWidth,
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// pictureCells gives the cells of a picture drawn at a scale whose pixels have the live colour.
+func pictureCells(picture image.Image, scale int, live color.Color) []util.Cell {
+	var cells []util.Cell
+	bounds := picture.Bounds()
+	r, g, b, _ := live.RGBA()
+	for y := 0; y < bounds.Dy()/scale; y++ {
+		for x := 0; x < bounds.Dx()/scale; x++ {
+			if pr, pg, pb, _ := picture.At(x*scale, y*scale).RGBA(); pr == r && pg == g && pb == b {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// TestPicture tests that the final world is output as a png image at the scale and in the palette of the params,
+// and that a recording has a frame every few turns from the input image to the final world, with either engine.
+func TestPicture(t *testing.T) {
+	live := color.RGBA{0, 0, 128, 255}
+	for _, engine := range []string{gol.StripsEngine, gol.HashLifeEngine} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, Engine: engine,
+			Output: "png", Scale: 3, Palette: "#ffffff,#000080", Record: 10}
+		t.Run(engine, func(t *testing.T) {
+			emptyOutFolder()
+			run := runEvents(p)
+			if len(run.errs) > 0 || fmt.Sprint(run.filenames) != "[16x16x100.gif 16x16x100.png]" {
+				t.Fatalf("ERROR: Expected a gif and a png image, got %v and errors %v", run.filenames, run.errs)
+			}
+
+			file, err := os.Open("out/16x16x100.png")
+			util.Check(err)
+			picture, err := png.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := picture.Bounds().Size(); size != image.Pt(48, 48) {
+				t.Errorf("ERROR: Expected a 48x48 png image, got %v", size)
+			}
+			assertEqualBoard(t, pictureCells(picture, 3, live), run.final.Alive, p)
+
+			file, err = os.Open("out/16x16x100.gif")
+			util.Check(err)
+			animation, err := gif.DecodeAll(file)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// HashLife may jump past the turns of frames, but not past the last one
+			if engine == gol.StripsEngine && len(animation.Image) != 11 {
+				t.Errorf("ERROR: Expected 11 frames, got %v", len(animation.Image))
+			}
+			first, last := animation.Image[0], animation.Image[len(animation.Image)-1]
+			assertEqualBoard(t, pictureCells(first, 3, live), readAliveCells("images/16x16.pgm", 16, 16), p)
+			assertEqualBoard(t, pictureCells(last, 3, live), run.final.Alive, p)
+		})
+	}
+}
+
+// TestPalette tests that the dying states of a Generations rule fade from the live to the dead colour,
+// and that palettes with more colours than states are refused.
+func TestPalette(t *testing.T) {
+	rule, err := util.ParseRule("B2/S/C4")
+	util.Check(err)
+	palette, err := util.ParsePalette("#000000,#ff0000,#00ff00", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := color.Palette{
+		color.RGBA{0, 0, 0, 255}, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{85, 0, 0, 255},
+	}
+	if fmt.Sprint(palette) != fmt.Sprint(expected) {
+		t.Errorf("ERROR: Expected %v, got %v", expected, palette)
+	}
+
+	grey, err := util.ParsePalette(util.GreyPalette, rule)
+	util.Check(err)
+	for state := range grey {
+		if value := rule.Value(state); grey[state] != (color.RGBA{value, value, value, 255}) {
+			t.Errorf("ERROR: Expected state %v to be grey %v, got %v", state, value, grey[state])
+		}
+	}
+
+	if _, err := util.ParsePalette("#000000,#ffffff,#808080,#404040,#202020", rule); err == nil {
+		t.Errorf("ERROR: Expected a palette of 5 colours to be refused for 4 states")
+	}
+}
+
+// TestRecordingCap tests that recordings of more than gol.MaxFrames frames are refused before the run starts,
+// counting the first and the last turn as frames.
+func TestRecordingCap(t *testing.T) {
+	for _, test := range []struct {
+		turns, record int
+		refused       bool
+	}{
+		{gol.MaxFrames - 1, 1, false},
+		{gol.MaxFrames, 1, true},
+		{10 * (gol.MaxFrames - 1), 10, false},
+		{10*(gol.MaxFrames-1) + 1, 10, true},
+		{10000000000, 1000, true},
+		{10000000000, 0, false},
+	} {
+		p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: test.turns, Record: test.record}
+		if err := gol.CheckFiles(p); (err != nil) != test.refused {
+			t.Errorf("ERROR: Expected recording every %v of %v turns to be refused %v, got %v",
+				test.record, test.turns, test.refused, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRle tests that the final world is output as an RLE file as well when asked to, and that a run
+// from that file goes on as if it had not stopped, against a stepper of its own.
+func TestRle(t *testing.T) {
+	for _, test := range []struct {
+		size int
+		rule string
+	}{
+		{16, "B3/S23"},
+		{64, "B3/S23"},
+		{64, "345/2/4"},
+	} {
+		rule, err := util.ParseRule(test.rule)
+		util.Check(err)
+		world := util.MakeWorld(test.size, test.size)
+		for _, cell := range readAliveCells(fmt.Sprintf("check/images/%vx%vx0.pgm", test.size, test.size), test.size, test.size) {
+			world[cell.Y][cell.X] = 255
+		}
+		expected := []string{fmt.Sprint(world)}
+		for turn := 1; turn <= 20; turn++ {
+			world = stepTorus(rule, world)
+			expected = append(expected, fmt.Sprint(world))
+		}
+
+		t.Run(fmt.Sprintf("%dx%d-%v", test.size, test.size, test.rule), func(t *testing.T) {
+			emptyOutFolder()
+			p := gol.Params{ImageWidth: test.size, ImageHeight: test.size, Turns: 10, Threads: 4, Rule: test.rule, Output: "pgm,rle"}
+			filenames := runEvents(p).filenames
+			name := fmt.Sprintf("%vx%vx%v", test.size, test.size, p.Turns)
+			if fmt.Sprint(filenames) != fmt.Sprint([]string{name, name + ".rle"}) {
+				t.Fatalf("ERROR: Expected the final image in %v and %v.rle, got %v", name, name, filenames)
+			}
+			pattern, err := util.LoadPattern("out/" + name + ".rle")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pattern.Rule != rule.String() {
+				t.Errorf("ERROR: Expected rule %v in the header, got %v", rule, pattern.Rule)
+			}
+			world := util.MakeWorld(test.size, test.size)
+			util.Check(pattern.Place(world, &util.Cell{}, rule))
+			if fmt.Sprint(world) != expected[p.Turns] {
+				t.Errorf("ERROR: The RLE file does not hold the world of turn %v", p.Turns)
+			}
+
+			p.Input, p.Output = "out/"+name+".rle", "rle"
+			filenames = runEvents(p).filenames
+			if len(filenames) != 1 {
+				t.Fatalf("ERROR: Expected the final image in one RLE file, got %v", filenames)
+			}
+			pattern, err = util.LoadPattern("out/" + filenames[0])
+			util.Check(err)
+			final := util.MakeWorld(test.size, test.size)
+			util.Check(pattern.Place(final, nil, rule))
+			if fmt.Sprint(final) != expected[2*p.Turns] {
+				t.Errorf("ERROR: A run from the RLE file does not reach the world of turn %v", 2*p.Turns)
+			}
+		})
+	}
+}
+
+// TestRleInput tests that a pattern is placed in the middle of the world or at an offset, cut off at the edges,
+// that the rule in its header is kept apart from any size Golly adds to it, and that patterns larger than
+// a netpbm image can be are refused before they are read.
+func TestRleInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glider.rle")
+	glider := "#N Glider\n#C A comment\nx = 3, y = 3, rule = B3/S23:T16,16\nbo$2bo$3o!\n"
+	util.Check(os.WriteFile(path, []byte(glider), 0644))
+
+	pattern, err := util.LoadPattern(path)
+	util.Check(err)
+	if pattern.Width != 3 || pattern.Height != 3 || pattern.Rule != "B3/S23" {
+		t.Errorf("ERROR: Expected a 3x3 pattern with rule B3/S23, got %vx%v with rule %v", pattern.Width, pattern.Height, pattern.Rule)
+	}
+
+	for _, test := range []struct {
+		offset   *util.Cell
+		expected []util.Cell
+	}{
+		{nil, []util.Cell{{X: 7, Y: 6}, {X: 8, Y: 7}, {X: 6, Y: 8}, {X: 7, Y: 8}, {X: 8, Y: 8}}},
+		{&util.Cell{X: 2, Y: 1}, []util.Cell{{X: 3, Y: 1}, {X: 4, Y: 2}, {X: 2, Y: 3}, {X: 3, Y: 3}, {X: 4, Y: 3}}},
+		{&util.Cell{X: 14, Y: -1}, []util.Cell{{X: 14, Y: 1}, {X: 15, Y: 1}}},
+	} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 1, Input: path, Offset: test.offset}
+		t.Run(fmt.Sprint(test.offset), func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), test.expected, p)
+		})
+	}
+
+	for _, rle := range []string{
+		"x = 4294967296, y = 4294967296\no!",
+		"x = 0, y = 0\n99999999999999999999o!",
+		"x = 0, y = 0\n1073741825o!",
+		"x = 0, y = 0\n2000000000$o!",
+	} {
+		if _, err := util.ReadRLE(strings.NewReader(rle)); err == nil {
+			t.Errorf("ERROR: Expected pattern %q to be refused", rle)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRule tests that the different notations of Conway's rule, including isotropic ones listing every configuration
+// and Larger than Life counting the cell itself, give the same 16x16 and 64x64 images after 100 turns,
+// and that Larger than Life rules with counts the neighbourhood cannot have are refused, by runs as well.
+func TestRule(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, p := range tests {
+		p.Turns = 100
+		p.Threads = 4
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, rule := range []string{"B3/S23", "b3/s23", "S23/B3", "23/3", "B3cekainyqjr/S2cekain3", "B3/S2-a2a3", "R1,C0,M1,S3..4,B3,NM"} {
+			p.Rule = rule
+			testName := fmt.Sprintf("%dx%dx%d-%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Rule)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+
+	for _, rulestring := range []string{"R2,C0,M0,S-1..3,B3,NM", "R1,C0,M0,S3..2,B3,NM", "R1,C0,M0,S2..9,B3,NM",
+		"R1,C0,M1,S2..3,B10,NM", "R1,C0,M2,S2..3,B3,NM"} {
+		if _, err := util.ParseRule(rulestring); err == nil {
+			t.Errorf("ERROR: Expected rule %v to be refused", rulestring)
+		}
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 1, Rule: rulestring}
+		if errs := runEvents(p).errs; len(errs) != 1 {
+			t.Errorf("ERROR: Expected a run with rule %v to quit with one IoError, got %v", rulestring, errs)
+		}
+	}
+}
+
+// TestLargerThanLife tests Bosco's Rule and its von Neumann and circular variants, which reach 5 cells away,
+// against stepping the 64x64 image cell by cell on a torus and on a plane.
+func TestLargerThanLife(t *testing.T) {
+	for _, rulestring := range []string{"R5,C0,M1,S34..58,B34..45,NM", "R5,C0,M1,S15..30,B15..22,NN", "R5,C0,M1,S27..46,B27..36,NC"} {
+		rule, err := util.ParseRule(rulestring)
+		util.Check(err)
+		inNeighbourhood := func(dx, dy int) bool {
+			switch rule.Neighbourhood {
+			case util.VonNeumann:
+				return abs(dx)+abs(dy) <= rule.Range
+			case util.Circular:
+				return dx*dx+dy*dy <= rule.Range*rule.Range
+			default:
+				return true
+			}
+		}
+		for _, topologyName := range []string{"torus", "plane"} {
+			topology, err := util.ParseTopology(topologyName)
+			util.Check(err)
+			p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 10, Threads: 4, Rule: rulestring, Topology: topologyName}
+
+			world := util.MakeWorld(p.ImageWidth, p.ImageHeight)
+			for _, cell := range readAliveCells("check/images/64x64x0.pgm", p.ImageWidth, p.ImageHeight) {
+				world[cell.Y][cell.X] = 255
+			}
+			for turn := 0; turn < p.Turns; turn++ {
+				immutableWorld := util.MakeTopologyWorld(world, topology)
+				next := util.MakeWorld(p.ImageWidth, p.ImageHeight)
+				for y := range next {
+					for x := range next[y] {
+						counter := 0
+						for dy := -rule.Range; dy <= rule.Range; dy++ {
+							for dx := -rule.Range; dx <= rule.Range; dx++ {
+								if (dx != 0 || dy != 0 || rule.Middle) && inNeighbourhood(dx, dy) && immutableWorld(y+dy, x+dx) == 255 {
+									counter++
+								}
+							}
+						}
+						next[y][x] = rule.Next(world[y][x], counter)
+					}
+				}
+				world = next
+			}
+
+			t.Run(fmt.Sprintf("%v-%v", rulestring, topologyName), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), worldAliveCells(world), p)
+			})
+		}
+	}
+}
+
+// TestGenerations tests that the CellsFlipped events of Generations rules rebuild the final world with valid states only.
+func TestGenerations(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 50, Threads: 4}
+	for _, rulestring := range []string{"/2/3", "345/2/4", "B3/S23/C5"} {
+		p.Rule = rulestring
+		rule, err := util.ParseRule(rulestring)
+		util.Check(err)
+		t.Run(rulestring, func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			world := util.MakeWorld(p.ImageWidth, p.ImageHeight)
+			var cells []util.Cell
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellsFlipped:
+					if len(e.Cells) != len(e.Values) {
+						t.Fatalf("ERROR: Expected a value for each of the %v flipped cells, got %v", len(e.Cells), len(e.Values))
+					}
+					for i, cell := range e.Cells {
+						world[cell.Y][cell.X] = e.Values[i]
+					}
+				case gol.FinalTurnComplete:
+					cells = e.Alive
+				}
+			}
+			var expectedAlive []util.Cell
+			for y := range world {
+				for x, value := range world[y] {
+					if rule.Value(rule.State(value)) != value {
+						t.Errorf("ERROR: Cell (%v, %v) has value %v, which is not a state of %v", x, y, value, rule)
+					}
+					if value == 255 {
+						expectedAlive = append(expectedAlive, util.Cell{X: x, Y: y})
+					}
+				}
+			}
+			assertEqualBoard(t, cells, expectedAlive, p)
+		})
+	}
+}
+
+// TestRuleFile tests that Conway's rule loaded from a rule table and from a rule tree gives the 16x16 and 64x64
+// images after 100 turns, that an electron of WireWorld goes round a loop of wire, and that the symmetries
+// of a table turn and mirror its transitions in each neighbourhood.
+func TestRuleFile(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, p := range tests {
+		p.Turns = 100
+		p.Threads = 4
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, rule := range []string{"rules/LifeTable.rule", "rules/LifeTree.rule"} {
+			p.Rule = rule
+			testName := fmt.Sprintf("%dx%dx%d-%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Rule)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+
+	t.Run("WireWorld", func(t *testing.T) {
+		rule, err := util.ParseRule("rules/WireWorld.rule")
+		util.Check(err)
+		// a loop of wire with a head followed by a tail, which goes round in 10 turns as it cuts the corners
+		rows := []string{
+			"........",
+			".332133.",
+			".3....3.",
+			".333333.",
+			"........",
+		}
+		world := util.MakeWorld(len(rows[0]), len(rows))
+		for y, row := range rows {
+			for x, c := range row {
+				if c != '.' {
+					world[y][x] = rule.Value(int(c - '0'))
+				}
+			}
+		}
+		next := world
+		for turn := 0; turn < 10; turn++ {
+			immutableWorld := util.MakeTopologyWorld(next, util.Plane)
+			next = util.MakeWorld(len(rows[0]), len(rows))
+			for y := range next {
+				for x := range next[y] {
+					next[y][x] = rule.NextTable(x, y, immutableWorld)
+				}
+			}
+		}
+		for y := range world {
+			for x := range world[y] {
+				if next[y][x] != world[y][x] {
+					t.Fatalf("ERROR: Expected state %v at (%v, %v) after 10 turns, got %v",
+						rule.State(world[y][x]), x, y, rule.State(next[y][x]))
+				}
+			}
+		}
+	})
+
+	// the neighbours around the middle of a 3x3 world
+	directions := map[string]util.Cell{
+		"N": {X: 1, Y: 0}, "NE": {X: 2, Y: 0}, "E": {X: 2, Y: 1}, "SE": {X: 2, Y: 2},
+		"S": {X: 1, Y: 2}, "SW": {X: 0, Y: 2}, "W": {X: 0, Y: 1}, "NW": {X: 0, Y: 0},
+	}
+	for _, test := range []struct {
+		neighbourhood, symmetries string
+		order                     []string // neighbours in the order of the neighbourhood
+		alive                     string   // neighbours of the one transition, which gives a birth
+		births                    []string // every set of up to 3 neighbours giving a birth
+	}{
+		{"Moore", "none", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N", []string{"N"}},
+		{"Moore", "rotate4", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N", []string{"N", "E", "S", "W"}},
+		{"Moore", "rotate8", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N",
+			[]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}},
+		{"Moore", "reflect_horizontal", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N,NE",
+			[]string{"N,NE", "N,NW"}},
+		{"Moore", "rotate4", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N,NE",
+			[]string{"N,NE", "E,SE", "S,SW", "W,NW"}},
+		{"Moore", "rotate4reflect", []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}, "N,NE",
+			[]string{"N,NE", "NE,E", "E,SE", "SE,S", "S,SW", "SW,W", "W,NW", "N,NW"}},
+		{"vonNeumann", "rotate4", []string{"N", "E", "S", "W"}, "N", []string{"N", "E", "S", "W"}},
+		{"vonNeumann", "reflect_horizontal", []string{"N", "E", "S", "W"}, "N,E", []string{"N,E", "N,W"}},
+		{"hexagonal", "rotate2", []string{"N", "E", "SE", "S", "W", "NW"}, "N", []string{"N", "S"}},
+		{"hexagonal", "rotate3", []string{"N", "E", "SE", "S", "W", "NW"}, "N", []string{"N", "SE", "W"}},
+		{"hexagonal", "rotate6", []string{"N", "E", "SE", "S", "W", "NW"}, "N,E,S",
+			[]string{"N,E,S", "E,SE,W", "SE,S,NW", "N,S,W", "E,W,NW", "N,SE,NW"}},
+		{"hexagonal", "rotate6reflect", []string{"N", "E", "SE", "S", "W", "NW"}, "N,E,S",
+			[]string{"N,E,S", "E,SE,W", "SE,S,NW", "N,S,W", "E,W,NW", "N,SE,NW",
+				"N,S,NW", "SE,W,NW", "E,S,W", "N,SE,S", "E,SE,NW", "N,E,W"}},
+		{"oneDimensional", "none", []string{"W", "E"}, "W", []string{"W"}},
+		{"oneDimensional", "reflect", []string{"W", "E"}, "W", []string{"W", "E"}},
+	} {
+		t.Run(fmt.Sprintf("%v-%v-%v", test.neighbourhood, test.symmetries, test.alive), func(t *testing.T) {
+			transition := []string{"0"}
+			for _, direction := range test.order {
+				if strings.Contains(","+test.alive+",", ","+direction+",") {
+					transition = append(transition, "1")
+				} else {
+					transition = append(transition, "0")
+				}
+			}
+			transition = append(transition, "1")
+			path := filepath.Join(t.TempDir(), "Symmetries.rule")
+			util.Check(os.WriteFile(path, []byte(fmt.Sprintf("@RULE Symmetries\n@TABLE\nn_states:2\nneighborhood:%v\nsymmetries:%v\n%v\n",
+				test.neighbourhood, test.symmetries, strings.Join(transition, ","))), 0644))
+			rule, err := util.ParseRule(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			births := make(map[string]bool)
+			for _, birth := range test.births {
+				births[birth] = true
+			}
+			// every set of up to 3 neighbours, named in the order of the neighbourhood
+			var sets [][]int
+			for i := range test.order {
+				sets = append(sets, []int{i})
+				for j := i + 1; j < len(test.order); j++ {
+					sets = append(sets, []int{i, j})
+					for k := j + 1; k < len(test.order); k++ {
+						sets = append(sets, []int{i, j, k})
+					}
+				}
+			}
+			for _, set := range sets {
+				world := util.MakeWorld(3, 3)
+				var names []string
+				for _, i := range set {
+					cell := directions[test.order[i]]
+					world[cell.Y][cell.X] = 255
+					names = append(names, test.order[i])
+				}
+				name := strings.Join(names, ",")
+				next := rule.NextTable(1, 1, util.MakeTopologyWorld(world, util.Plane))
+				if born := next == 255; born != births[name] {
+					t.Errorf("ERROR: Expected a birth with %v alive to be %v, got %v", name, births[name], born)
+				}
+				delete(births, name)
+			}
+			if len(births) > 0 {
+				var missing []string
+				for birth := range births {
+					missing = append(missing, birth)
+				}
+				sort.Strings(missing)
+				t.Errorf("ERROR: Expected births %v are not sets of neighbours in order", missing)
+			}
+		})
+	}
+}
+
+// TestStochastic tests that a rule whose births happen by chance gives the same world whatever the number
+// of threads, that a different seed gives a different world, and that births which always happen give Conway's rule.
+func TestStochastic(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Rule: "B3(0.95)/S2(0.99)3", Seed: 42}
+	p.Threads = 1
+	expectedAlive := finalAlive(p)
+	for threads := 2; threads <= 8; threads++ {
+		p.Threads = threads
+		t.Run(fmt.Sprintf("%v-%d", p.Rule, threads), func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+		})
+	}
+
+	t.Run("Seed", func(t *testing.T) {
+		p.Seed = 43
+		if checkEqualBoard(finalAlive(p), expectedAlive) {
+			t.Errorf("ERROR: Expected seeds 42 and 43 to give different worlds after %v turns", p.Turns)
+		}
+	})
+
+	t.Run("B3(1)/S23", func(t *testing.T) {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, Rule: "B3(1)/S23"}
+		expectedAlive := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+		assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+	})
+}
+
+// TestGrid tests that every cell of a hexagonal or triangular grid is a neighbour of its own neighbours,
+// and that rules on these grids give the same world as stepping the 64x64 image cell by cell.
+func TestGrid(t *testing.T) {
+	for _, grid := range []util.Grid{util.HexagonalGrid, util.TriangularGrid} {
+		t.Run(grid.String(), func(t *testing.T) {
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 2; x++ {
+					for _, n := range grid.Neighbours(x, y) {
+						found := false
+						for _, back := range grid.Neighbours(x+n.X, y+n.Y) {
+							found = found || back.X == -n.X && back.Y == -n.Y
+						}
+						if !found {
+							t.Errorf("ERROR: Expected (%v, %v) to be a neighbour of its neighbour (%v, %v)", x, y, x+n.X, y+n.Y)
+						}
+					}
+				}
+			}
+		})
+	}
+
+	for _, rulestring := range []string{"B2/S34H", "B2/S/C3H", "B45/S456L"} {
+		rule, err := util.ParseRule(rulestring)
+		util.Check(err)
+		for _, topologyName := range []string{"torus", "plane"} {
+			topology, err := util.ParseTopology(topologyName)
+			util.Check(err)
+			p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 20, Threads: 4, Rule: rulestring, Topology: topologyName}
+
+			world := util.MakeWorld(p.ImageWidth, p.ImageHeight)
+			for _, cell := range readAliveCells("check/images/64x64x0.pgm", p.ImageWidth, p.ImageHeight) {
+				world[cell.Y][cell.X] = 255
+			}
+			for turn := 0; turn < p.Turns; turn++ {
+				immutableWorld := util.MakeTopologyWorld(world, topology)
+				next := util.MakeWorld(p.ImageWidth, p.ImageHeight)
+				for y := range next {
+					for x := range next[y] {
+						counter := 0
+						for _, n := range rule.Grid.Neighbours(x, y) {
+							if immutableWorld(y+n.Y, x+n.X) == 255 {
+								counter++
+							}
+						}
+						next[y][x] = rule.Next(immutableWorld(y, x), counter)
+					}
+				}
+				world = next
+			}
+			var expectedAlive []util.Cell
+			for y := range world {
+				for x, value := range world[y] {
+					if value == 255 {
+						expectedAlive = append(expectedAlive, util.Cell{X: x, Y: y})
+					}
+				}
+			}
+
+			t.Run(fmt.Sprintf("%v-%v", rulestring, topologyName), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestBlock tests that block rules give the same world as replacing the blocks of the 64x64 image one by one,
+// whatever the number of threads and the size of the tiles, and that the billiard ball model keeps its balls.
+func TestBlock(t *testing.T) {
+	for _, rulestring := range []string{
+		"MS,D0;8;4;3;2;5;9;7;1;6;10;11;12;13;14;15", // billiard ball model
+		"MS,D15;14;13;3;11;5;6;1;7;9;10;2;12;4;8;0", // Critters
+	} {
+		rule, err := util.ParseRule(rulestring)
+		util.Check(err)
+		for _, topologyName := range []string{"torus", "plane"} {
+			topology, err := util.ParseTopology(topologyName)
+			util.Check(err)
+
+			world := util.MakeWorld(64, 64)
+			initialAlive := readAliveCells("check/images/64x64x0.pgm", 64, 64)
+			for _, cell := range initialAlive {
+				world[cell.Y][cell.X] = 255
+			}
+			turns := 25
+			for turn := 1; turn <= turns; turn++ {
+				immutableWorld := util.MakeTopologyWorld(world, topology)
+				next := util.MakeWorld(64, 64)
+				offset := (turn - 1) % 2
+				for top := offset - 2; top < 64; top += 2 {
+					for left := offset - 2; left < 64; left += 2 {
+						block := 0
+						for i := 0; i < 4; i++ {
+							if immutableWorld(top+i/2, left+i%2) == 255 {
+								block |= 1 << i
+							}
+						}
+						for i := 0; i < 4; i++ {
+							x, y := left+i%2, top+i/2
+							if x >= 0 && y >= 0 && x < 64 && y < 64 && rule.Block[block]&(1<<i) != 0 {
+								next[y][x] = 255
+							}
+						}
+					}
+				}
+				world = next
+			}
+			var expectedAlive []util.Cell
+			for y := range world {
+				for x, value := range world[y] {
+					if value == 255 {
+						expectedAlive = append(expectedAlive, util.Cell{X: x, Y: y})
+					}
+				}
+			}
+			if rule.Block[1] == 8 && topology == util.Torus && len(expectedAlive) != len(initialAlive) {
+				t.Errorf("ERROR: Expected the %v balls of the billiard ball model to stay, got %v", len(initialAlive), len(expectedAlive))
+			}
+
+			for _, threads := range []int{1, 5} {
+				p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: turns, Threads: threads, Rule: rulestring, Topology: topologyName, TileSize: 7}
+				t.Run(fmt.Sprintf("%v-%v-%v", rulestring, topologyName, threads), func(t *testing.T) {
+					assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+				})
+			}
+		}
+	}
+}
+
+// TestCubic tests 3D rules against a stepper of its own, which starts from the 64x64 image as the middle slice,
+// and that each slice of the final world is output as an image of its own.
+func TestCubic(t *testing.T) {
+	for rulestring, expected := range map[string]string{"4555": "4555", "5,7,6,6": "5766", "4,5,5,10": "4,5,5,10"} {
+		rule, err := util.ParseRule(rulestring)
+		if err != nil || !rule.Cubic || rule.String() != expected {
+			t.Errorf("ERROR: Expected rule %q to be the 3D rule %v, got %v (%v)", rulestring, expected, rule, err)
+		}
+	}
+
+	const width, height, depth, turns = 64, 64, 5, 12
+	for _, rulestring := range []string{"4555", "5766"} {
+		rule, err := util.ParseRule(rulestring)
+		util.Check(err)
+		for _, topologyName := range []string{"torus", "plane"} {
+			topology, err := util.ParseTopology(topologyName)
+			util.Check(err)
+
+			world := util.MakeWorld(width, height*depth)
+			for _, cell := range readAliveCells("check/images/64x64x0.pgm", width, height) {
+				world[depth/2*height+cell.Y][cell.X] = 255
+			}
+			for turn := 1; turn <= turns; turn++ {
+				next := util.MakeWorld(width, height*depth)
+				for z := 0; z < depth; z++ {
+					for y := 0; y < height; y++ {
+						for x := 0; x < width; x++ {
+							count := 0
+							for dz := -1; dz <= 1; dz++ {
+								for dy := -1; dy <= 1; dy++ {
+									for dx := -1; dx <= 1; dx++ {
+										nx, ny, nz := x+dx, y+dy, z+dz
+										if topology == util.Torus {
+											nx, ny, nz = (nx+width)%width, (ny+height)%height, (nz+depth)%depth
+										} else if nx < 0 || ny < 0 || nz < 0 || nx >= width || ny >= height || nz >= depth {
+											continue
+										}
+										if (dx != 0 || dy != 0 || dz != 0) && world[nz*height+ny][nx] == 255 {
+											count++
+										}
+									}
+								}
+							}
+							if world[z*height+y][x] == 255 && rule.Survive[count] || world[z*height+y][x] == 0 && rule.Birth[count] {
+								next[z*height+y][x] = 255
+							}
+						}
+					}
+				}
+				world = next
+			}
+			expectedSlices := make([][]util.Cell, depth)
+			var expectedAlive []util.Cell
+			for y := range world {
+				for x, value := range world[y] {
+					if value == 255 {
+						expectedAlive = append(expectedAlive, util.Cell{X: x, Y: y})
+						expectedSlices[y/height] = append(expectedSlices[y/height], util.Cell{X: x, Y: y % height})
+					}
+				}
+			}
+			if len(expectedAlive) == 0 {
+				t.Errorf("ERROR: Expected rule %v to leave live cells on a %v", rulestring, topologyName)
+			}
+
+			for _, threads := range []int{1, 5} {
+				p := gol.Params{ImageWidth: width, ImageHeight: height, ImageDepth: depth, Turns: turns, Threads: threads, Rule: rulestring, Topology: topologyName, TileSize: 7}
+				t.Run(fmt.Sprintf("%v-%v-%v", rulestring, topologyName, threads), func(t *testing.T) {
+					run := runEvents(p)
+					filenames := run.filenames
+					assertEqualBoard(t, run.final.Alive, expectedAlive, p)
+					if len(filenames) != depth {
+						t.Fatalf("ERROR: Expected an image for each of the %v slices, got %v", depth, filenames)
+					}
+					for z, filename := range filenames {
+						if expectedName := fmt.Sprintf("%vx%vx%vx%v_z%v", width, height, depth, turns, z); filename != expectedName {
+							t.Errorf("ERROR: Expected slice %v in %v, got %v", z, expectedName, filename)
+						}
+						assertEqualBoard(t, readAliveCells("out/"+filename+".pgm", width, height), expectedSlices[z], p)
+					}
+				})
+			}
+		}
+	}
+}
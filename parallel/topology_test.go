@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestTopology tests the number of alive cells of the 64x64 image after 40 turns on each topology.
+// The pattern reaches the edges by then, so every topology ends up with a different count.
+func TestTopology(t *testing.T) {
+	expected := map[string]int{
+		"torus":     158,
+		"plane":     132,
+		"alive":     490,
+		"hcylinder": 152,
+		"vcylinder": 178,
+		"klein":     215,
+		"cross":     229,
+	}
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 40}
+	for topology, alive := range expected {
+		for _, threads := range []int{1, 3} {
+			p.Topology = topology
+			p.Threads = threads
+			testName := fmt.Sprintf("%v-%d", topology, threads)
+			t.Run(testName, func(t *testing.T) {
+				if cells := finalAlive(p); len(cells) != alive {
+					t.Errorf("ERROR: Expected %v alive cells on a %v, got %v", alive, topology, len(cells))
+				}
+			})
+		}
+	}
+}
+
+// TestUnbounded tests that the glider of the 16x16 image leaves the image in an unbounded universe,
+// and the number of alive cells of the 64x64 image after 300 turns, which outgrows its image.
+func TestUnbounded(t *testing.T) {
+	tests := []struct {
+		p     gol.Params
+		alive int
+	}{
+		{gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 300}, 5},
+		{gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 300}, 594},
+	}
+	for _, test := range tests {
+		p := test.p
+		p.Topology = "unbounded"
+		p.Threads = 4
+		testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+		t.Run(testName, func(t *testing.T) {
+			cells := finalAlive(p)
+			if len(cells) != test.alive {
+				t.Errorf("ERROR: Expected %v alive cells, got %v", test.alive, len(cells))
+			}
+			var outside bool
+			for _, cell := range cells {
+				if cell.X < 0 || cell.Y < 0 || cell.X >= p.ImageWidth || cell.Y >= p.ImageHeight {
+					outside = true
+				}
+			}
+			if !outside {
+				t.Errorf("ERROR: Expected alive cells outside of the %vx%v image", p.ImageWidth, p.ImageHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// firstTurn gives the first turn that meets a condition, or -1 if none does.
+func firstTurn(turns int, met func(turn int) bool) int {
+	for turn := 0; turn <= turns; turn++ {
+		if met(turn) {
+			return turn
+		}
+	}
+	return -1
+}
+
+// TestUntil tests that each stopping condition finishes the run in the first turn that meets it, against a stepper
+// of its own, with the usual final events and the condition as the reason.
+func TestUntil(t *testing.T) {
+	const turns, stableBox = 1000, 50
+	life, boxes := untilTurns("B3/S23", turns)
+	diamoeba, _ := untilTurns("B35678/S5678", turns)
+	seeds, _ := untilTurns("B3/S", turns)
+	boxSince := 0
+
+	tests := []struct {
+		params       gol.Params
+		reason       gol.StopReason
+		expectedTurn int
+	}{
+		{gol.Params{MinPopulation: life[0] / 10}, gol.PopulationBelow,
+			firstTurn(turns, func(turn int) bool { return life[turn] < life[0]/10 })},
+		{gol.Params{Rule: "B35678/S5678", MaxPopulation: diamoeba[0]}, gol.PopulationAbove,
+			firstTurn(turns, func(turn int) bool { return diamoeba[turn] > diamoeba[0] })},
+		{gol.Params{Rule: "B3/S", StopOnExtinction: true}, gol.Extinct,
+			firstTurn(turns, func(turn int) bool { return seeds[turn] == 0 })},
+		{gol.Params{StableBox: stableBox}, gol.BoxUnchanged,
+			firstTurn(turns, func(turn int) bool {
+				if turn > 0 && boxes[turn] != boxes[turn-1] {
+					boxSince = turn
+				}
+				return turn-boxSince >= stableBox
+			})},
+		{gol.Params{TimeLimit: 300 * time.Millisecond}, gol.TimeLimitReached, -1},
+		{gol.Params{MinSpeed: 1e12}, gol.TooSlow, -1},
+		{gol.Params{Turns: 100}, gol.TurnsCompleted, 100},
+	}
+	for _, test := range tests {
+		p := test.params
+		p.ImageWidth, p.ImageHeight, p.Threads = 64, 64, 4
+		if p.Turns == 0 {
+			p.Turns = 100000000
+		}
+		t.Run(fmt.Sprintf("%v", test.reason), func(t *testing.T) {
+			if test.reason != gol.TimeLimitReached && test.reason != gol.TooSlow && test.expectedTurn <= 0 {
+				t.Fatalf("ERROR: Expected the stepper to meet the condition after turn 0, got %v", test.expectedTurn)
+			}
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var final gol.FinalTurnComplete
+			var sequence []string
+			for event := range events {
+				switch e := event.(type) {
+				case gol.FinalTurnComplete:
+					final = e
+					sequence = append(sequence, "final")
+				case gol.ImageOutputComplete:
+					sequence = append(sequence, "image")
+				case gol.StateChange:
+					if e.NewState == gol.Quitting {
+						sequence = append(sequence, "quitting")
+					}
+				}
+			}
+			if fmt.Sprint(sequence) != "[final image quitting]" {
+				t.Errorf("ERROR: Expected the final turn, the image and quitting, got %v", sequence)
+			}
+			if final.Reason != test.reason {
+				t.Errorf("ERROR: Expected the run to finish as %v, got %v", test.reason, final.Reason)
+			}
+			if test.expectedTurn >= 0 && final.CompletedTurns != test.expectedTurn {
+				t.Errorf("ERROR: Expected the run to finish in turn %v, got %v", test.expectedTurn, final.CompletedTurns)
+			}
+			if test.expectedTurn < 0 && final.CompletedTurns >= p.Turns {
+				t.Errorf("ERROR: Expected the run to finish before turn %v", p.Turns)
+			}
+		})
+	}
+}
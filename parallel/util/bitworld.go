@@ -0,0 +1,67 @@
+package util
+
+import "math/bits"
+
+// BitWorld is a world of two-state cells packed 64 to a word: cell x of a row is bit x%64 of word x/64.
+// Bits past the width of the world are always 0.
+type BitWorld struct {
+	Width, Height int
+	Rows          [][]uint64
+}
+
+// Words gives the number of words in a row of a world width cells wide.
+func Words(width int) int {
+	return (width + 63) / 64
+}
+
+func MakeBitWorld(width, height int) *BitWorld {
+	rows := make([][]uint64, height)
+	for i := range rows {
+		rows[i] = make([]uint64, Words(width))
+	}
+	return &BitWorld{Width: width, Height: height, Rows: rows}
+}
+
+// PackWorld packs the live cells of a world.
+func PackWorld(world [][]byte) *BitWorld {
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	packed := MakeBitWorld(width, len(world))
+	for y, row := range world {
+		for x, value := range row {
+			if value == liveValue {
+				packed.Rows[y][x/64] |= 1 << (x % 64)
+			}
+		}
+	}
+	return packed
+}
+
+// Unpack gives the world as bytes.
+func (w *BitWorld) Unpack() [][]byte {
+	world := MakeWorld(w.Width, w.Height)
+	for y, row := range w.Rows {
+		for x := range world[y] {
+			if row[x/64]&(1<<(x%64)) != 0 {
+				world[y][x] = liveValue
+			}
+		}
+	}
+	return world
+}
+
+// Alive reports whether the cell (x, y) is alive.
+func (w *BitWorld) Alive(x, y int) bool {
+	return w.Rows[y][x/64]&(1<<(x%64)) != 0
+}
+
+// AppendCells appends a cell of row y to cells for each set bit of a word that holds the cells from x onwards.
+func AppendCells(cells []Cell, word uint64, x, y int) []Cell {
+	for word != 0 {
+		cells = append(cells, Cell{X: x + bits.TrailingZeros64(word), Y: y})
+		word &= word - 1
+	}
+	return cells
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// margolusPrefix starts the rulestrings of block rules.
+const margolusPrefix = "MS,D"
+
+// parseMargolus reads a block rule in the notation of MCell and Golly: "MS,D" followed by the 16 entries
+// of its table separated by ';', e.g. "MS,D0;8;4;3;2;5;9;7;1;6;10;11;12;13;14;15" (the billiard ball model).
+// The world is cut into blocks of 2x2 cells, and each turn every block is replaced by the entry of the table
+// for its cells, which are numbered 1 for the top left, 2 for the top right, 4 for the bottom left and 8
+// for the bottom right. The blocks start on even rows and columns in the first turn and on odd ones in the next,
+// and so on, see NextBlock. The table must be a permutation, so that the automaton can be run backwards.
+func parseMargolus(rulestring string) (Rule, error) {
+	rule := Rule{
+		Birth:   make([]bool, 9),
+		Survive: make([]bool, 9),
+		States:  2,
+		Range:   1,
+		Block:   make([]byte, 16),
+	}
+	entries := strings.Split(strings.TrimSpace(rulestring)[len(margolusPrefix):], ";")
+	if len(entries) != len(rule.Block) {
+		return rule, fmt.Errorf("invalid rule %q: expected %v entries separated by ';', got %v", rulestring, len(rule.Block), len(entries))
+	}
+	var seen [16]bool
+	for i, entry := range entries {
+		n, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || n < 0 || n >= len(rule.Block) {
+			return rule, fmt.Errorf("invalid rule %q: entry %q must be between 0 and 15", rulestring, entry)
+		}
+		if seen[n] {
+			return rule, fmt.Errorf("invalid rule %q: the table is not a permutation, %v appears twice", rulestring, n)
+		}
+		seen[n] = true
+		rule.Block[i] = byte(n)
+	}
+	return rule, nil
+}
+
+func (r Rule) writeMargolus(sb *strings.Builder) {
+	sb.WriteString(margolusPrefix)
+	for i, entry := range r.Block {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		sb.WriteString(strconv.Itoa(int(entry)))
+	}
+}
+
+// NextBlock gives the value of cell (x, y) in turn for block rules, counting the first turn as 1.
+// world is indexed [y][x] and decides what lies beyond the edges, as in util.MakeTopologyWorld.
+// Each cell finds its own block from its position, so the world can be split between workers anywhere,
+// as long as x and y are the position of the cell in the whole world.
+func (r *Rule) NextBlock(x, y, turn int, world func(int, int) byte) byte {
+	offset := (turn - 1) & 1
+	left, top := x-((x-offset)&1), y-((y-offset)&1)
+	block := 0
+	for i := 0; i < 4; i++ {
+		if world(top+i/2, left+i%2) == liveValue {
+			block |= 1 << i
+		}
+	}
+	if r.Block[block]&(1<<((x-left)+2*(y-top))) != 0 {
+		return liveValue
+	}
+	return deadValue
+}
+
+// CheckSize reports whether a width x height world with the given topology can run the rule.
+// Besides the checks of Grid.Check, the blocks of block rules must fit whole between edges that are joined,
+// which they cannot do with a twist.
+func (r *Rule) CheckSize(t Topology, width, height int) error {
+	if err := r.Grid.Check(t, width, height); err != nil || r.Block == nil {
+		return err
+	}
+	switch t {
+	case KleinBottle, CrossSurface:
+		return fmt.Errorf("the %v topology cannot join the edges of the blocks of rule %v", t, *r)
+	case Torus, VerticalCylinder:
+		if height%2 != 0 {
+			return fmt.Errorf("the %v topology needs an even height for the blocks of rule %v, got %v", t, *r, height)
+		}
+	}
+	switch t {
+	case Torus, HorizontalCylinder:
+		if width%2 != 0 {
+			return fmt.Errorf("the %v topology needs an even width for the blocks of rule %v, got %v", t, *r, width)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package util
+
+import "fmt"
+
+// Grid describes the shape of the cells of the world and which cells are neighbours.
+// Every grid is stored in the same [][]byte, with its cells in rows.
+type Grid int
+
+const (
+	// SquareGrid has square cells, each with the 8 cells around it as neighbours.
+	SquareGrid Grid = iota
+	// HexagonalGrid has hexagonal cells with 6 neighbours. Odd rows are shifted right by half a cell.
+	HexagonalGrid
+	// TriangularGrid has triangular cells with the 12 neighbours they share a corner with.
+	// Cell (x, y) points up when x+y is even and down when it is odd.
+	TriangularGrid
+)
+
+var gridNames = []string{"square", "hexagonal", "triangular"}
+
+// gridSuffixes follow the rulestrings of the grids, e.g. "B2/S34H".
+var gridSuffixes = []string{"", "H", "L"}
+
+var (
+	squareNeighbours = []Cell{
+		{X: 0, Y: 1}, {X: 0, Y: -1},
+		{X: 1, Y: 0}, {X: -1, Y: 0},
+		{X: -1, Y: -1}, {X: -1, Y: 1},
+		{X: 1, Y: 1}, {X: 1, Y: -1},
+	}
+	// neighbours of the cells in even and odd rows
+	hexagonalNeighbours = [2][]Cell{
+		{{X: -1, Y: 0}, {X: 1, Y: 0}, {X: -1, Y: -1}, {X: 0, Y: -1}, {X: -1, Y: 1}, {X: 0, Y: 1}},
+		{{X: -1, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: -1}, {X: 1, Y: -1}, {X: 0, Y: 1}, {X: 1, Y: 1}},
+	}
+	// neighbours of the cells pointing up and down: 3 cells on the side of the point, 5 on the side of the base
+	triangularNeighbours = [2][]Cell{
+		{
+			{X: -2, Y: 0}, {X: -1, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0},
+			{X: -1, Y: -1}, {X: 0, Y: -1}, {X: 1, Y: -1},
+			{X: -2, Y: 1}, {X: -1, Y: 1}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1},
+		},
+		{
+			{X: -2, Y: 0}, {X: -1, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0},
+			{X: -1, Y: 1}, {X: 0, Y: 1}, {X: 1, Y: 1},
+			{X: -2, Y: -1}, {X: -1, Y: -1}, {X: 0, Y: -1}, {X: 1, Y: -1}, {X: 2, Y: -1},
+		},
+	}
+)
+
+func (g Grid) String() string {
+	if g < 0 || int(g) >= len(gridNames) {
+		return "Incorrect Grid"
+	}
+	return gridNames[g]
+}
+
+// Neighbours gives where the neighbours of cell (x, y) lie relative to it,
+// which on hexagonal and triangular grids depends on the cell.
+func (g Grid) Neighbours(x, y int) []Cell {
+	switch g {
+	case HexagonalGrid:
+		return hexagonalNeighbours[y&1]
+	case TriangularGrid:
+		return triangularNeighbours[(x+y)&1]
+	default:
+		return squareNeighbours
+	}
+}
+
+// Range gives how many cells away the neighbours of a cell can lie.
+func (g Grid) Range() int {
+	if g == TriangularGrid {
+		return 2
+	}
+	return 1
+}
+
+// Check reports whether a width x height world with the given topology can hold the grid. Edges joined
+// to each other must keep odd rows of a hexagonal grid next to even ones, and triangles pointing up next to
+// triangles pointing down, so they need an even number of cells between them, and they cannot be twisted.
+func (g Grid) Check(t Topology, width, height int) error {
+	if g == SquareGrid {
+		return nil
+	}
+	switch t {
+	case KleinBottle, CrossSurface:
+		return fmt.Errorf("the %v topology cannot join the edges of a %v grid", t, g)
+	case Torus, VerticalCylinder:
+		if height%2 != 0 {
+			return fmt.Errorf("the %v topology needs an even height for a %v grid, got %v", t, g, height)
+		}
+	}
+	switch t {
+	case Torus, HorizontalCylinder:
+		if g == TriangularGrid && width%2 != 0 {
+			return fmt.Errorf("the %v topology needs an even width for a %v grid, got %v", t, g, width)
+		}
+	}
+	return nil
+}
@@ -40,6 +40,14 @@ func MakeNextAliveCellChannel() chan []Cell {
 	return make(chan []Cell)
 }
 
+func MakeTurnChannels(threads int) []chan bool {
+	chs := make([]chan bool, threads)
+	for i := 0; i < threads; i++ {
+		chs[i] = make(chan bool)
+	}
+	return chs
+}
+
 func MakeBoolChannel() chan bool {
 	return make(chan bool)
 }
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// life106Header is the first line of a Life 1.06 file.
+const life106Header = "#Life 1.06"
+
+// ReadLife106 reads a pattern in the Life 1.06 format, which lists the coordinates of the live cells:
+//
+//	#Life 1.06
+//	0 -1
+//	1 0
+//	-1 1
+//	0 1
+//	1 1
+//
+// The coordinates may be negative, and the pattern is the bounding box of the cells.
+// Other lines starting with # are taken as comments, which Golly writes to some files.
+func ReadLife106(r io.Reader) (Pattern, error) {
+	scanner := bufio.NewScanner(r)
+	header := false
+	var cells []Cell
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !header {
+			if text != life106Header {
+				return Pattern{}, fmt.Errorf("expected the header %q, got %q", life106Header, text)
+			}
+			header = true
+			continue
+		}
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		var c Cell
+		if _, err := fmt.Sscan(text, &c.X, &c.Y); err != nil {
+			return Pattern{}, fmt.Errorf("invalid cell %q on line %v", text, line)
+		}
+		cells = append(cells, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return Pattern{}, err
+	}
+	if !header {
+		return Pattern{}, fmt.Errorf("no header line")
+	}
+	if len(cells) == 0 {
+		return newPattern(0, 0), nil
+	}
+	low, high := cells[0], cells[0]
+	for _, c := range cells {
+		if c.X < low.X {
+			low.X = c.X
+		}
+		if c.Y < low.Y {
+			low.Y = c.Y
+		}
+		if c.X > high.X {
+			high.X = c.X
+		}
+		if c.Y > high.Y {
+			high.Y = c.Y
+		}
+	}
+	// the difference of two coordinates fits in an unsigned integer even where it overflows an int
+	spanX, spanY := uint64(high.X)-uint64(low.X), uint64(high.Y)-uint64(low.Y)
+	if spanX >= maxImagePixels || spanY >= maxImagePixels || (spanX+1)*(spanY+1) > maxImagePixels {
+		return Pattern{}, fmt.Errorf("cells from %v to %v are too far apart", low, high)
+	}
+	pattern := newPattern(int(spanX)+1, int(spanY)+1)
+	for _, c := range cells {
+		pattern.Cells[c.Y-low.Y][c.X-low.X] = 1
+	}
+	return pattern, nil
+}
+
+// WriteLife106 writes the cells in the Life 1.06 format ReadLife106 reads, one cell a line.
+func WriteLife106(w io.Writer, cells []Cell) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, life106Header)
+	for _, c := range cells {
+		fmt.Fprintf(out, "%d %d\n", c.X, c.Y)
+	}
+	return out.Flush()
+}
+
+// LiveCells gives the cells of a world that are alive, as cells of the universe whose top left cell is origin.
+func LiveCells(world [][]byte, origin Cell) []Cell {
+	var cells []Cell
+	for y, row := range world {
+		for x, value := range row {
+			if value == liveValue {
+				cells = append(cells, Cell{X: x + origin.X, Y: y + origin.Y})
+			}
+		}
+	}
+	return cells
+}
@@ -0,0 +1,37 @@
+package util
+
+// Noise draws the random numbers of a stochastic rule. Each cell gets its own number every turn, hashed from
+// the seed, the turn and the cell, so a run comes out the same whichever threads or servers step the cells.
+type Noise struct {
+	Seed   uint64
+	Turn   int  // turn being calculated
+	Origin Cell // universe cell at the top left of the world, for unbounded universes
+}
+
+// Dice gives the number of cell (x, y) of the world in this turn, between 0 and 1.
+func (n Noise) Dice(x, y int) float64 {
+	z := mix64(n.Seed ^ mix64(uint64(n.Turn)))
+	z = mix64(z ^ uint64(x+n.Origin.X))
+	z = mix64(z ^ uint64(y+n.Origin.Y)*0x9e3779b97f4a7c15)
+	return float64(z>>11) / (1 << 53)
+}
+
+// mix64 is the finaliser of SplitMix64, which spreads every bit of its input over the whole output.
+func mix64(z uint64) uint64 {
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// CellHash gives the random key of cell (x, y) of the universe holding value, for Zobrist hashing:
+// the hash of a world is the XOR of the keys of its cells that are not dead, so a cell that changes
+// moves the hash by the keys of its old and new values, whatever the other cells hold.
+func CellHash(x, y int, value byte) uint64 {
+	if value == deadValue {
+		return 0
+	}
+	z := mix64(uint64(x))
+	z = mix64(z ^ uint64(y)*0x9e3779b97f4a7c15)
+	return mix64(z ^ uint64(value))
+}
@@ -0,0 +1,268 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rleLineLength is the longest line WriteRLE writes, which most readers expect RLE lines to stay within.
+const rleLineLength = 70
+
+// ReadRLE reads a pattern in Run Length Encoded format, as used by Golly and LifeWiki:
+//
+//	#N Glider
+//	x = 3, y = 3, rule = B3/S23
+//	bob$2bo$3o!
+//
+// Lines starting with # are comments. The header gives the size of the pattern and optionally its rule,
+// without the :T suffix Golly adds for bounded grids. Each cell state is a tag with an optional count:
+// b or . is dead, o or A is live, and B to X, then pA to yO, are the further states of multi-state rules.
+// $ ends a row and ! ends the pattern. Any other letter counts as live, which some old files rely on.
+func ReadRLE(r io.Reader) (Pattern, error) {
+	scanner := bufio.NewScanner(r)
+	width, height := -1, -1
+	rule := ""
+	for width < 0 && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var err error
+		if width, height, rule, err = parseRLEHeader(line); err != nil {
+			return Pattern{}, err
+		}
+	}
+	if width < 0 {
+		return Pattern{}, fmt.Errorf("no header line")
+	}
+
+	var rows [][]byte
+	var row []byte
+	count, prefix := 0, byte(0)
+	// cells counts the cells read so far, which are held to maxImagePixels like the size in the header
+	cells := 0
+	run := func(state byte) error {
+		if count == 0 {
+			count = 1
+		}
+		if cells += count; cells > maxImagePixels {
+			return fmt.Errorf("pattern has more than %v cells", maxImagePixels)
+		}
+		for ; count > 0; count-- {
+			row = append(row, state)
+		}
+		return nil
+	}
+body:
+	for scanner.Scan() {
+		for _, c := range []byte(scanner.Text()) {
+			var err error
+			if prefix != 0 && (c < 'A' || c > 'X') {
+				return Pattern{}, fmt.Errorf("state prefix %q not followed by A to X", prefix)
+			}
+			switch {
+			case c >= '0' && c <= '9':
+				if count = 10*count + int(c-'0'); count > maxImagePixels {
+					return Pattern{}, fmt.Errorf("run of more than %v cells", maxImagePixels)
+				}
+			case c == ' ' || c == '\t' || c == '\r':
+			case c == '!':
+				break body
+			case c == '$':
+				if len(rows)+count > maxImagePixels {
+					return Pattern{}, fmt.Errorf("pattern has more than %v rows", maxImagePixels)
+				}
+				rows = append(rows, row)
+				row = nil
+				for count--; count > 0; count-- {
+					rows = append(rows, nil)
+				}
+				count = 0
+			case c == 'b' || c == '.':
+				err = run(0)
+			case c >= 'p' && c <= 'y':
+				prefix = c
+			case c >= 'A' && c <= 'X':
+				state := int(c-'A') + 1
+				if prefix != 0 {
+					state += 24 * int(prefix-'p'+1)
+					prefix = 0
+				}
+				if state > 255 {
+					return Pattern{}, fmt.Errorf("state %v is out of range", state)
+				}
+				err = run(byte(state))
+			case c >= 'a' && c <= 'z':
+				err = run(1)
+			default:
+				return Pattern{}, fmt.Errorf("unexpected %q in the pattern", c)
+			}
+			if err != nil {
+				return Pattern{}, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Pattern{}, err
+	}
+	rows = append(rows, row)
+
+	// the header is trusted for the size, unless the cells run past it
+	if len(rows) > height {
+		height = len(rows)
+	}
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	if height > 0 && width > maxImagePixels/height {
+		return Pattern{}, fmt.Errorf("pattern of %vx%v cells is too large", width, height)
+	}
+	pattern := newPattern(width, height)
+	pattern.Rule = rule
+	for y, row := range rows {
+		copy(pattern.Cells[y], row)
+	}
+	return pattern, nil
+}
+
+// parseRLEHeader reads the "x = m, y = n, rule = r" line of an RLE file.
+func parseRLEHeader(line string) (width, height int, rule string, err error) {
+	width, height = -1, -1
+	parts := strings.Split(line, ",")
+	for i, part := range parts {
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return 0, 0, "", fmt.Errorf("invalid header %q", part)
+		}
+		key, value := strings.TrimSpace(part[:eq]), strings.TrimSpace(part[eq+1:])
+		if key == "rule" {
+			// the rule is the last part of the header, and may have commas of its own
+			rule = strings.TrimSpace(strings.Join(append([]string{value}, parts[i+1:]...), ","))
+			if colon := strings.Index(rule, ":"); colon >= 0 {
+				rule = rule[:colon]
+			}
+			break
+		}
+		if key == "x" || key == "y" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return 0, 0, "", fmt.Errorf("invalid size %v = %q", key, value)
+			}
+			if key == "x" {
+				width = n
+			} else {
+				height = n
+			}
+		}
+	}
+	if width < 0 || height < 0 {
+		return 0, 0, "", fmt.Errorf("header has no size")
+	}
+	if height > 0 && width > maxImagePixels/height {
+		return 0, 0, "", fmt.Errorf("pattern of %vx%v cells is too large", width, height)
+	}
+	return width, height, rule, nil
+}
+
+// PatternOf gives the pattern of a world, whose values become the states of the rule.
+func PatternOf(world [][]byte, rule Rule) Pattern {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	pattern := newPattern(width, height)
+	pattern.Rule = rule.String()
+	for y, row := range world {
+		for x, value := range row {
+			pattern.Cells[y][x] = byte(rule.State(value))
+		}
+	}
+	return pattern
+}
+
+// WriteRLE writes the pattern in the Run Length Encoded format ReadRLE reads, after a # line for each comment.
+// Two-state patterns use b and o for their cells, and multi-state patterns . and A to X with their prefixes.
+func (p Pattern) WriteRLE(w io.Writer, comments ...string) error {
+	states := 2
+	for _, row := range p.Cells {
+		for _, state := range row {
+			if int(state) >= states {
+				states = int(state) + 1
+			}
+		}
+	}
+	out := bufio.NewWriter(w)
+	for _, comment := range comments {
+		fmt.Fprintf(out, "#%v\n", comment)
+	}
+	fmt.Fprintf(out, "x = %v, y = %v", p.Width, p.Height)
+	if p.Rule != "" {
+		fmt.Fprintf(out, ", rule = %v", p.Rule)
+	}
+	fmt.Fprintln(out)
+
+	line := 0
+	put := func(count int, tag string) {
+		item := tag
+		if count > 1 {
+			item = strconv.Itoa(count) + tag
+		}
+		if line+len(item) > rleLineLength {
+			fmt.Fprintln(out)
+			line = 0
+		}
+		out.WriteString(item)
+		line += len(item)
+	}
+	rowEnds := 0
+	for _, row := range p.Cells {
+		// dead cells at the end of a row are left out
+		end := len(row)
+		for end > 0 && row[end-1] == 0 {
+			end--
+		}
+		if end == 0 {
+			rowEnds++
+			continue
+		}
+		if rowEnds > 0 {
+			put(rowEnds, "$")
+		}
+		for x := 0; x < end; {
+			n := 1
+			for x+n < end && row[x+n] == row[x] {
+				n++
+			}
+			put(n, rleTag(row[x], states))
+			x += n
+		}
+		rowEnds = 1
+	}
+	put(1, "!")
+	fmt.Fprintln(out)
+	return out.Flush()
+}
+
+// rleTag gives the tag of a state in a pattern with the given number of states.
+func rleTag(state byte, states int) string {
+	if states <= 2 {
+		if state == 0 {
+			return "b"
+		}
+		return "o"
+	}
+	if state == 0 {
+		return "."
+	}
+	s := int(state) - 1
+	if s < 24 {
+		return string(rune('A' + s))
+	}
+	return string([]rune{rune('p' + s/24 - 1), rune('A' + s%24)})
+}
@@ -0,0 +1,544 @@
+package util
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// DefaultRule is the rulestring of Conway's Game of Life.
+const DefaultRule = "B3/S23"
+
+const (
+	deadValue byte = 0
+	liveValue byte = 255
+)
+
+// Neighbourhood is the shape of the cells around a cell that count as its neighbours.
+type Neighbourhood int
+
+const (
+	Moore Neighbourhood = iota
+	VonNeumann
+	Circular
+)
+
+// Rule describes a Life-like, Generations or Larger than Life cellular automaton.
+// Birth[n] is true when a dead cell with n live neighbours can become alive,
+// Survive[n] is true when a live cell with n live neighbours can stay alive.
+// States is the number of cell states: 2 for Life-like rules, more for Generations rules,
+// where a live cell that does not survive decays through States-2 dying states before it is dead.
+// Isotropic is set for non-totalistic rules in Hensel notation, e.g. "B2-a/S12", which depend on the arrangement
+// of the live neighbours and not only on their number. BirthConfigs and SurviveConfigs are then indexed by the
+// neighbourhood bitmask described in hensel.go; they are filled in for every rule with a range of 1.
+// Range, Neighbourhood and Middle describe which cells are counted: the Moore neighbourhood of range 1 without the
+// cell itself for all but Larger than Life rules. Grid is the shape of the cells, which for hexagonal and triangular
+// grids decides the neighbours instead, see Grid.Neighbours. Block rules replace each 2x2 block of cells
+// with the entry of Block for it instead, see parseMargolus. Cubic rules run on a 3D world of cubes and count
+// the 26 cubes around each one, see parseBays.
+// Rules loaded from a Golly rule file have a Name and a Tree instead of counts, see LoadRuleFile.
+// Stochastic rules give the chance that a birth or survival with n live neighbours happens in BirthChance[n]
+// and SurviveChance[n], which are nil when every one of them happens, see Roll.
+type Rule struct {
+	Birth          []bool
+	Survive        []bool
+	States         int
+	Isotropic      bool
+	BirthConfigs   [256]bool
+	SurviveConfigs [256]bool
+	Range          int
+	Neighbourhood  Neighbourhood
+	Middle         bool
+	Name           string
+	Tree           *RuleTree
+	Colours        [][3]byte // red, green and blue of each state, or nil to draw the states in grey
+	BirthChance    []float64
+	SurviveChance  []float64
+	Grid           Grid
+	Block          []byte
+	Cubic          bool
+}
+
+// ParseRule reads a rulestring in B/S notation, e.g. "B3/S23" (Life), "B36/S23" (HighLife) or "B2/S" (Seeds).
+// The older S/B notation without letters, e.g. "23/3", is accepted as well.
+// Generations rules take the number of states as a third part, either as S/B/C, e.g. "/2/3" (Brian's Brain)
+// and "345/2/4" (Star Wars), or as B/S/C, e.g. "B2/S/C3".
+// Each count may be followed by Hensel letters to pick isotropic configurations, e.g. "B2a" or "S2-i"
+// for all configurations but i, and by the chance that it happens in brackets, e.g. "B3(0.95)/S23".
+// A rulestring ending in H runs on a hexagonal grid and one ending in L on a triangular grid, e.g. "B2/S34H",
+// which do not take Hensel letters. Counts go up to 6 on a hexagonal grid. A triangle has 12 neighbours,
+// but only counts up to 9 can be written.
+// Larger than Life rules are described in parseLargerThanLife, block rules starting with "MS,D" in parseMargolus,
+// and 3D rules of four numbers, e.g. "4555", in parseBays.
+// A rulestring ending in .rule is the path of a Golly rule file.
+// An empty rulestring gives Conway's Game of Life.
+func ParseRule(rulestring string) (Rule, error) {
+	rs := strings.TrimSpace(rulestring)
+	if rs == "" {
+		rs = DefaultRule
+	}
+	if strings.HasSuffix(strings.ToLower(rs), ".rule") {
+		return LoadRuleFile(rs)
+	}
+	if strings.HasPrefix(strings.ToUpper(rs), "R") {
+		return parseLargerThanLife(rs)
+	}
+	if strings.HasPrefix(strings.ToUpper(rs), margolusPrefix) {
+		return parseMargolus(rs)
+	}
+	if isBays(rs) {
+		return parseBays(rs)
+	}
+	grid := SquareGrid
+	for g, suffix := range gridSuffixes {
+		if suffix != "" && strings.HasSuffix(strings.ToUpper(rs), suffix) {
+			grid = Grid(g)
+			rs = rs[:len(rs)-1]
+			break
+		}
+	}
+	size := len(grid.Neighbours(0, 0))
+	rule := Rule{
+		Birth:   make([]bool, size+1),
+		Survive: make([]bool, size+1),
+		States:  2,
+		Range:   grid.Range(),
+		Grid:    grid,
+	}
+
+	parts := strings.Split(rs, "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		return rule, fmt.Errorf("invalid rule %q: expected two or three parts separated by '/'", rulestring)
+	}
+	// Only the B, S and C prefixes are case-insensitive, Hensel letters are lower case.
+	for i, part := range parts {
+		if strings.HasPrefix(part, "b") || strings.HasPrefix(part, "s") || strings.HasPrefix(part, "c") {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	var birth, survive string
+	switch {
+	case strings.HasPrefix(parts[0], "B") && strings.HasPrefix(parts[1], "S"):
+		birth, survive = parts[0][1:], parts[1][1:]
+	case strings.HasPrefix(parts[0], "S") && strings.HasPrefix(parts[1], "B"):
+		survive, birth = parts[0][1:], parts[1][1:]
+	default:
+		// S/B notation, e.g. 23/3
+		survive, birth = parts[0], parts[1]
+	}
+
+	birthChance, survivalChance := make([]float64, size+1), make([]float64, size+1)
+	isotropicBirth, err := parseCounts(birth, rule.Birth, &rule.BirthConfigs, birthChance)
+	if err != nil {
+		return rule, fmt.Errorf("invalid rule %q: %v", rulestring, err)
+	}
+	isotropicSurvive, err := parseCounts(survive, rule.Survive, &rule.SurviveConfigs, survivalChance)
+	if err != nil {
+		return rule, fmt.Errorf("invalid rule %q: %v", rulestring, err)
+	}
+	rule.Isotropic = isotropicBirth || isotropicSurvive
+	if rule.Isotropic && grid != SquareGrid {
+		return rule, fmt.Errorf("invalid rule %q: Hensel letters need a square grid", rulestring)
+	}
+	for n := range birthChance {
+		if birthChance[n] < 1 || survivalChance[n] < 1 {
+			rule.BirthChance, rule.SurviveChance = birthChance, survivalChance
+		}
+	}
+
+	if len(parts) == 3 {
+		states, err := strconv.Atoi(strings.TrimPrefix(parts[2], "C"))
+		if err != nil || states < 2 || states > 256 {
+			return rule, fmt.Errorf("invalid rule %q: number of states must be between 2 and 256", rulestring)
+		}
+		rule.States = states
+	}
+	return rule, nil
+}
+
+// parseCounts reads one half of a rulestring, setting the counts and the isotropic configurations it includes,
+// and the chance of each count, which is 1 unless it is given. It reports whether any count was restricted
+// with Hensel letters.
+func parseCounts(part string, counts []bool, configs *[256]bool, chances []float64) (bool, error) {
+	for n := range chances {
+		chances[n] = 1
+	}
+	isotropic := false
+	for i := 0; i < len(part); {
+		d := part[i]
+		if d < '0' || d > '9' {
+			return false, fmt.Errorf("unexpected %q in neighbour counts", d)
+		}
+		count := int(d - '0')
+		if count >= len(counts) {
+			return false, fmt.Errorf("a cell has no more than %v neighbours, got %v", len(counts)-1, count)
+		}
+		i++
+
+		negate := i < len(part) && part[i] == '-'
+		if negate {
+			i++
+		}
+		letters := ""
+		for i < len(part) && part[i] >= 'a' && part[i] <= 'z' {
+			if count > 8 || strings.IndexByte(henselLetters[count], part[i]) < 0 {
+				return false, fmt.Errorf("no configuration %c with %v neighbours", part[i], count)
+			}
+			letters += string(part[i])
+			i++
+		}
+		if negate && letters == "" {
+			return false, fmt.Errorf("expected Hensel letters after '-'")
+		}
+		isotropic = isotropic || letters != ""
+
+		if i < len(part) && part[i] == '(' {
+			end := strings.IndexByte(part[i:], ')')
+			if end < 0 {
+				return false, fmt.Errorf("expected ')' after the chance of %v neighbours", count)
+			}
+			chance, err := strconv.ParseFloat(part[i+1:i+end], 64)
+			if err != nil || !(chance > 0 && chance <= 1) {
+				return false, fmt.Errorf("invalid chance %q of %v neighbours", part[i+1:i+end], count)
+			}
+			chances[count] = chance
+			i += end + 1
+		}
+
+		// only the 8 neighbours of a square cell have configurations
+		if letters == "" {
+			counts[count] = true
+		}
+		for neighbourhood := 0; neighbourhood < 256; neighbourhood++ {
+			if bits.OnesCount8(uint8(neighbourhood)) != count {
+				continue
+			}
+			included := letters == "" || strings.IndexByte(letters, HenselLetter(uint8(neighbourhood))) >= 0 != negate
+			if included {
+				configs[neighbourhood] = true
+				counts[count] = true
+			}
+		}
+	}
+	return isotropic, nil
+}
+
+// parseLargerThanLife reads a Larger than Life rulestring, e.g. "R5,C0,M1,S34..58,B34..45,NM" (Bosco's Rule).
+// R is the range, C the number of states (0 for two), M1 counts the cell itself as a neighbour,
+// S and B give a range of counts each and may be repeated, and N picks the Moore (M), von Neumann (N)
+// or circular (C) neighbourhood.
+func parseLargerThanLife(rulestring string) (Rule, error) {
+	rule := Rule{States: 2, Range: 1}
+	var birth, survive [][2]int
+	for _, field := range strings.Split(strings.ToUpper(rulestring), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value := field[1:]
+		var err error
+		switch field[0] {
+		case 'R':
+			rule.Range, err = strconv.Atoi(value)
+			if err == nil && (rule.Range < 1 || rule.Range > 100) {
+				err = fmt.Errorf("range must be between 1 and 100")
+			}
+		case 'C':
+			rule.States, err = strconv.Atoi(value)
+			if err == nil && (rule.States < 0 || rule.States > 256) {
+				err = fmt.Errorf("number of states must be between 0 and 256")
+			} else if rule.States < 2 {
+				rule.States = 2
+			}
+		case 'M':
+			switch value {
+			case "0", "1":
+				rule.Middle = value == "1"
+			default:
+				err = fmt.Errorf("middle must be 0 or 1")
+			}
+		case 'S', 'B':
+			var counts [2]int
+			counts, err = parseCountRange(value)
+			if field[0] == 'S' {
+				survive = append(survive, counts)
+			} else {
+				birth = append(birth, counts)
+			}
+		case 'N':
+			switch value {
+			case "M":
+				rule.Neighbourhood = Moore
+			case "N":
+				rule.Neighbourhood = VonNeumann
+			case "C":
+				rule.Neighbourhood = Circular
+			default:
+				err = fmt.Errorf("unknown neighbourhood %q", value)
+			}
+		default:
+			err = fmt.Errorf("unexpected field %q", field)
+		}
+		if err != nil {
+			return rule, fmt.Errorf("invalid rule %q: %v", rulestring, err)
+		}
+	}
+
+	size := rule.NeighbourhoodSize()
+	rule.Birth = make([]bool, size+1)
+	rule.Survive = make([]bool, size+1)
+	for _, counts := range append(birth, survive...) {
+		if counts[1] > size {
+			return rule, fmt.Errorf("invalid rule %q: count %v is more than the %v cells of the neighbourhood",
+				rulestring, counts[1], size)
+		}
+	}
+	for _, counts := range birth {
+		for n := counts[0]; n <= counts[1]; n++ {
+			rule.Birth[n] = true
+		}
+	}
+	for _, counts := range survive {
+		for n := counts[0]; n <= counts[1]; n++ {
+			rule.Survive[n] = true
+		}
+	}
+	return rule, nil
+}
+
+// parseCountRange reads "min..max" or a single count.
+func parseCountRange(value string) ([2]int, error) {
+	bounds := strings.SplitN(value, "..", 2)
+	low, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid count %q", value)
+	}
+	high := low
+	if len(bounds) == 2 {
+		high, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return [2]int{}, fmt.Errorf("invalid count %q", value)
+		}
+	}
+	if low < 0 || low > high {
+		return [2]int{}, fmt.Errorf("invalid count range %q", value)
+	}
+	return [2]int{low, high}, nil
+}
+
+// LargerThanLife reports whether the rule looks further than the 8 cells around a cell of a square grid.
+func (r *Rule) LargerThanLife() bool {
+	return r.Grid == SquareGrid && (r.Range > 1 || r.Neighbourhood != Moore || r.Middle)
+}
+
+// RowExtent gives how far the neighbourhood reaches left and right in the row dy rows above or below a cell.
+func (r *Rule) RowExtent(dy int) int {
+	if dy < 0 {
+		dy = -dy
+	}
+	switch r.Neighbourhood {
+	case VonNeumann:
+		return r.Range - dy
+	case Circular:
+		extent := r.Range
+		for extent*extent+dy*dy > r.Range*r.Range {
+			extent--
+		}
+		return extent
+	default:
+		return r.Range
+	}
+}
+
+// NeighbourhoodSize gives the number of cells counted as neighbours, including the cell itself if Middle is set.
+func (r *Rule) NeighbourhoodSize() int {
+	if r.Cubic {
+		return cubicNeighbours
+	}
+	if r.Grid != SquareGrid {
+		return len(r.Grid.Neighbours(0, 0))
+	}
+	size := 0
+	for dy := -r.Range; dy <= r.Range; dy++ {
+		size += 2*r.RowExtent(dy) + 1
+	}
+	if !r.Middle {
+		size--
+	}
+	return size
+}
+
+// String gives the rule back in B/S notation, with the number of states appended for Generations rules,
+// or in Larger than Life, block or 3D notation. Rules loaded from a rule file give their name.
+func (r Rule) String() string {
+	if r.Tree != nil {
+		return r.Name
+	}
+	var sb strings.Builder
+	if r.Block != nil {
+		r.writeMargolus(&sb)
+		return sb.String()
+	}
+	if r.Cubic {
+		r.writeBays(&sb)
+		return sb.String()
+	}
+	if r.LargerThanLife() {
+		r.writeLargerThanLife(&sb)
+		return sb.String()
+	}
+	sb.WriteString("B")
+	r.writeCounts(&sb, r.Birth, r.BirthConfigs, r.BirthChance)
+	sb.WriteString("/S")
+	r.writeCounts(&sb, r.Survive, r.SurviveConfigs, r.SurviveChance)
+	if r.States > 2 {
+		sb.WriteString("/C" + strconv.Itoa(r.States))
+	}
+	sb.WriteString(gridSuffixes[r.Grid])
+	return sb.String()
+}
+
+func (r Rule) writeLargerThanLife(sb *strings.Builder) {
+	states, middle := r.States, 0
+	if states == 2 {
+		states = 0
+	}
+	if r.Middle {
+		middle = 1
+	}
+	sb.WriteString(fmt.Sprintf("R%v,C%v,M%v", r.Range, states, middle))
+	for _, field := range []struct {
+		prefix string
+		counts []bool
+	}{{"S", r.Survive}, {"B", r.Birth}} {
+		for n := 0; n < len(field.counts); n++ {
+			if !field.counts[n] {
+				continue
+			}
+			start := n
+			for n+1 < len(field.counts) && field.counts[n+1] {
+				n++
+			}
+			sb.WriteString(fmt.Sprintf(",%v%v..%v", field.prefix, start, n))
+		}
+	}
+	sb.WriteString(",N" + []string{"M", "N", "C"}[r.Neighbourhood])
+}
+
+func (r Rule) writeCounts(sb *strings.Builder, counts []bool, configs [256]bool, chances []float64) {
+	for n, included := range counts {
+		if !included {
+			continue
+		}
+		sb.WriteByte(byte('0' + n))
+		if r.Isotropic {
+			writeLetters(sb, n, configs)
+		}
+		if chances != nil && chances[n] < 1 {
+			sb.WriteString("(" + strconv.FormatFloat(chances[n], 'g', -1, 64) + ")")
+		}
+	}
+}
+
+// writeLetters writes the Hensel letters of the configurations with n live neighbours, or nothing if they are all included.
+func writeLetters(sb *strings.Builder, n int, configs [256]bool) {
+	var with, without string
+	for _, letter := range []byte(henselLetters[n]) {
+		found := false
+		for neighbourhood, config := range configs {
+			if config && bits.OnesCount8(uint8(neighbourhood)) == n && HenselLetter(uint8(neighbourhood)) == letter {
+				found = true
+				break
+			}
+		}
+		if found {
+			with += string(letter)
+		} else {
+			without += string(letter)
+		}
+	}
+	if without != "" {
+		if len(with) <= len(without) {
+			sb.WriteString(with)
+		} else {
+			sb.WriteString("-" + without)
+		}
+	}
+}
+
+// Value gives the grey level a state is stored as in the world.
+// State 0 is dead (0), state 1 is alive (255) and the dying states fade evenly towards black.
+func (r *Rule) Value(state int) byte {
+	if state <= 0 || state >= r.States {
+		return deadValue
+	}
+	return liveValue - byte((state-1)*int(liveValue)/(r.States-1))
+}
+
+// State gives the state of a grey level, picking the nearest dying state for values that Value would not produce.
+func (r *Rule) State(value byte) int {
+	switch {
+	case value == deadValue:
+		return 0
+	case value == liveValue:
+		return 1
+	case r.States <= 2:
+		return 0
+	}
+	state := 1 + (int(liveValue-value)*(r.States-1)+int(liveValue)/2)/int(liveValue)
+	if state < 2 {
+		state = 2
+	} else if state >= r.States {
+		state = r.States - 1
+	}
+	return state
+}
+
+// Next gives the value of a cell in the next turn from its current value and its number of live neighbours.
+// Only cells in the live state count as neighbours, and the cell itself only counts for rules with Middle set.
+func (r *Rule) Next(value byte, liveNeighbours int) byte {
+	return r.next(value, r.Birth[liveNeighbours], r.Survive[liveNeighbours])
+}
+
+// NextIsotropic gives the value of a cell in the next turn from its current value and its neighbourhood bitmask.
+// It is needed instead of Next when the rule is Isotropic.
+func (r *Rule) NextIsotropic(value byte, neighbourhood uint8) byte {
+	return r.next(value, r.BirthConfigs[neighbourhood], r.SurviveConfigs[neighbourhood])
+}
+
+func (r *Rule) next(value byte, born, survives bool) byte {
+	switch state := r.State(value); state {
+	case 0:
+		if born {
+			return liveValue
+		}
+		return deadValue
+	case 1:
+		if survives {
+			return liveValue
+		}
+		return r.Value(2)
+	default:
+		return r.Value(state + 1)
+	}
+}
+
+// Stochastic reports whether births or survivals of the rule only happen by chance.
+func (r *Rule) Stochastic() bool {
+	return r.BirthChance != nil
+}
+
+// Roll decides whether the birth or survival of a cell with the given number of live neighbours happens
+// in a stochastic rule, giving next if it does and the value the cell would have without it otherwise.
+// dice is a random number between 0 and 1, see Noise.
+func (r *Rule) Roll(value, next byte, liveNeighbours int, dice float64) byte {
+	switch {
+	case value == deadValue && next == liveValue && dice >= r.BirthChance[liveNeighbours]:
+		return deadValue
+	case value == liveValue && next == liveValue && dice >= r.SurviveChance[liveNeighbours]:
+		return r.next(value, false, false)
+	}
+	return next
+}
@@ -0,0 +1,494 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tableNeighbourhood lists the neighbours of a cell in the order the transitions of a @TABLE give them,
+// which goes round the cell except for the one-dimensional neighbourhood, and the symmetries it can have.
+type tableNeighbourhood struct {
+	neighbours []Cell
+	symmetries []string
+}
+
+var tableNeighbourhoods = map[string]tableNeighbourhood{
+	"moore": {
+		[]Cell{{X: 0, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}, {X: -1, Y: 1}, {X: -1, Y: 0}, {X: -1, Y: -1}},
+		[]string{"none", "rotate4", "rotate8", "rotate4reflect", "rotate8reflect", "reflect_horizontal", "permute"},
+	},
+	"vonneumann": {
+		[]Cell{{X: 0, Y: -1}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}},
+		[]string{"none", "rotate4", "rotate4reflect", "reflect_horizontal", "permute"},
+	},
+	// hexagonal cells are squares whose north-west and south-east neighbours touch them as well
+	"hexagonal": {
+		[]Cell{{X: 0, Y: -1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}, {X: -1, Y: 0}, {X: -1, Y: -1}},
+		[]string{"none", "rotate2", "rotate3", "rotate6", "rotate6reflect", "permute"},
+	},
+	"onedimensional": {
+		[]Cell{{X: -1, Y: 0}, {X: 1, Y: 0}},
+		[]string{"none", "reflect", "permute"},
+	},
+}
+
+// stateSet is a set of the states 0 to 255.
+type stateSet [4]uint64
+
+func (s *stateSet) add(state int) {
+	s[state/64] |= 1 << (state % 64)
+}
+
+func (s *stateSet) has(state int) bool {
+	return s[state/64]&(1<<(state%64)) != 0
+}
+
+func (s *stateSet) less(t *stateSet) bool {
+	for i := range s {
+		if s[i] != t[i] {
+			return s[i] < t[i]
+		}
+	}
+	return false
+}
+
+// tableToken is a state or a variable in a transition of a @TABLE. A variable that appears once is the set
+// of its states, and a bound one keeps its name, as it takes one of its values everywhere in the transition.
+type tableToken struct {
+	states stateSet
+	bound  string
+	values []int // of a bound variable
+}
+
+func (t *tableToken) less(u *tableToken) bool {
+	if t.bound != u.bound {
+		return t.bound < u.bound
+	}
+	return t.states.less(&u.states)
+}
+
+// tableTransition is a transition of a @TABLE once its variables are bound. inputs holds the states
+// the cell and then each of its neighbours can have for the transition to apply.
+type tableTransition struct {
+	inputs  []stateSet
+	output  int
+	anyFrom int // inputs from here on hold every state
+}
+
+// compileTable reads the @TABLE section of a rule file and builds the decision tree of its transitions.
+// After n_states, neighborhood (Moore, vonNeumann, hexagonal or oneDimensional) and symmetries come variables,
+// e.g. "var a={0,1,2}", and transitions, e.g. "0,a,1,a,..,2" or "0a1a..2" for rules with up to 10 states,
+// which give the states of the cell and of its neighbours, then its next state. A variable that appears
+// more than once in a transition, or as its next state, takes the same state everywhere in it.
+// Symmetries add the transition with the neighbours turned or mirrored, or in every order for permute,
+// right after it. The first transition that applies to a cell gives its next state, and cells without one
+// stay as they are.
+func compileTable(lines []ruleLine) (*RuleTree, int, error) {
+	states := 0
+	var neighbourhood tableNeighbourhood
+	symmetry := "none"
+	variables := make(map[string][]int)
+	var transitions []tableTransition
+	var orders [][]int
+	started := false
+
+	for _, line := range lines {
+		if strings.HasPrefix(line.text, "var ") {
+			name, values, err := parseTableVariable(line.text[len("var "):], states, variables)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %v: %v", line.number, err)
+			}
+			variables[name] = values
+			continue
+		}
+		if i := strings.IndexByte(line.text, ':'); i >= 0 {
+			key, value := strings.TrimSpace(line.text[:i]), strings.TrimSpace(line.text[i+1:])
+			if started {
+				return nil, 0, fmt.Errorf("line %v: %v must come before the transitions", line.number, key)
+			}
+			switch key {
+			case "n_states", "num_states":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 2 || n > 256 {
+					return nil, 0, fmt.Errorf("line %v: %v must be between 2 and 256", line.number, key)
+				}
+				states = n
+			case "neighborhood", "neighbourhood":
+				var ok bool
+				if neighbourhood, ok = tableNeighbourhoods[strings.ToLower(value)]; !ok {
+					return nil, 0, fmt.Errorf("line %v: unknown neighbourhood %q", line.number, value)
+				}
+			case "symmetries":
+				symmetry = value
+			default:
+				return nil, 0, fmt.Errorf("line %v: unknown setting %q", line.number, key)
+			}
+			continue
+		}
+
+		if !started {
+			if states == 0 || neighbourhood.neighbours == nil {
+				return nil, 0, fmt.Errorf("line %v: expected n_states and neighborhood before the transitions", line.number)
+			}
+			var err error
+			if orders, err = tableSymmetries(symmetry, neighbourhood); err != nil {
+				return nil, 0, fmt.Errorf("line %v: %v", line.number, err)
+			}
+			started = true
+		}
+		tokens, err := parseTransition(line.text, states, len(neighbourhood.neighbours), variables)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %v: %v", line.number, err)
+		}
+		for _, turned := range symmetricTransitions(tokens, orders) {
+			transitions = append(transitions, bindTransition(turned)...)
+		}
+	}
+	if states == 0 || neighbourhood.neighbours == nil {
+		return nil, 0, fmt.Errorf("expected n_states and neighborhood")
+	}
+	transitions = withoutRepeats(transitions)
+
+	// cells without a transition stay as they are
+	var all stateSet
+	for state := 0; state < states; state++ {
+		all.add(state)
+	}
+	for state := 0; state < states; state++ {
+		t := tableTransition{inputs: make([]stateSet, len(neighbourhood.neighbours)+1), output: state}
+		t.inputs[0].add(state)
+		for i := 1; i < len(t.inputs); i++ {
+			t.inputs[i] = all
+		}
+		transitions = append(transitions, t)
+	}
+	for i := range transitions {
+		t := &transitions[i]
+		t.anyFrom = len(t.inputs)
+		for t.anyFrom > 0 && t.inputs[t.anyFrom-1] == all {
+			t.anyFrom--
+		}
+	}
+
+	b := treeBuilder{
+		states:      states,
+		levels:      len(neighbourhood.neighbours) + 1,
+		transitions: transitions,
+		built:       make(map[string]int32),
+		nodes:       make(map[string]int32),
+		tree:        &RuleTree{Offsets: append([]Cell{{}}, neighbourhood.neighbours...)},
+	}
+	candidates := make([]int32, len(transitions))
+	for i := range candidates {
+		candidates[i] = int32(i)
+	}
+	b.tree.Root = b.build(0, candidates)
+	return b.tree, states, nil
+}
+
+// parseTableVariable reads the definition of a variable after "var", e.g. "a={0,1,2}",
+// whose values can be states or other variables.
+func parseTableVariable(definition string, states int, variables map[string][]int) (string, []int, error) {
+	i := strings.IndexByte(definition, '=')
+	if i < 0 {
+		return "", nil, fmt.Errorf("expected a variable such as var a={0,1}")
+	}
+	name, list := strings.TrimSpace(definition[:i]), strings.TrimSpace(definition[i+1:])
+	if name == "" || !strings.HasPrefix(list, "{") || !strings.HasSuffix(list, "}") {
+		return "", nil, fmt.Errorf("expected a variable such as var a={0,1}")
+	}
+	var values []int
+	for _, token := range strings.Split(list[1:len(list)-1], ",") {
+		set, err := tableStates(strings.TrimSpace(token), states, variables)
+		if err != nil {
+			return "", nil, err
+		}
+		values = append(values, set...)
+	}
+	return name, values, nil
+}
+
+// tableStates gives the states a state or variable stands for.
+func tableStates(token string, states int, variables map[string][]int) ([]int, error) {
+	if values, ok := variables[token]; ok {
+		return values, nil
+	}
+	state, err := strconv.Atoi(token)
+	if err != nil {
+		return nil, fmt.Errorf("unknown variable %q", token)
+	}
+	if state < 0 || state >= states {
+		return nil, fmt.Errorf("no state %v", state)
+	}
+	return []int{state}, nil
+}
+
+// parseTransition reads the tokens of a transition: the cell, its neighbours and its next state.
+func parseTransition(text string, states, neighbours int, variables map[string][]int) ([]tableToken, error) {
+	var words []string
+	if strings.Contains(text, ",") {
+		for _, word := range strings.Split(text, ",") {
+			words = append(words, strings.TrimSpace(word))
+		}
+	} else if states <= 10 {
+		for _, c := range text {
+			if c != ' ' && c != '\t' {
+				words = append(words, string(c))
+			}
+		}
+	}
+	if len(words) != neighbours+2 {
+		return nil, fmt.Errorf("expected the cell, its %v neighbours and its next state separated by commas", neighbours)
+	}
+
+	uses := make(map[string]int)
+	for _, word := range words {
+		uses[word]++
+	}
+	tokens := make([]tableToken, len(words))
+	for i, word := range words {
+		values, err := tableStates(word, states, variables)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := variables[word]; ok && uses[word] > 1 {
+			tokens[i] = tableToken{bound: word, values: values}
+			continue
+		}
+		if i == len(words)-1 && len(values) != 1 {
+			return nil, fmt.Errorf("next state %v is a variable that does not appear before it", word)
+		}
+		for _, state := range values {
+			tokens[i].states.add(state)
+		}
+	}
+	return tokens, nil
+}
+
+// tableSymmetries gives the orders the neighbours of a transition are also read in, as indices into the neighbours,
+// or nil for permute, where every order counts.
+func tableSymmetries(symmetry string, neighbourhood tableNeighbourhood) ([][]int, error) {
+	known := false
+	for _, s := range neighbourhood.symmetries {
+		known = known || s == symmetry
+	}
+	if !known {
+		return nil, fmt.Errorf("symmetries %q do not apply to the neighbourhood, expected one of %v", symmetry,
+			strings.Join(neighbourhood.symmetries, ", "))
+	}
+	if symmetry == "permute" {
+		return nil, nil
+	}
+
+	n := len(neighbourhood.neighbours)
+	turn := func(steps int) []int {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = (i + steps) % n
+		}
+		return order
+	}
+	// mirror fixes the first neighbour, which is the one to the north
+	mirror := func(order []int) []int {
+		mirrored := make([]int, n)
+		for i := range mirrored {
+			mirrored[i] = order[(n-i)%n]
+		}
+		return mirrored
+	}
+
+	orders := [][]int{turn(0)}
+	switch {
+	case symmetry == "reflect":
+		orders = append(orders, []int{1, 0})
+	case symmetry == "reflect_horizontal":
+		orders = append(orders, mirror(turn(0)))
+	case strings.HasPrefix(symmetry, "rotate"):
+		rotations, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(symmetry, "rotate"), "reflect"))
+		for r := 1; r < rotations; r++ {
+			orders = append(orders, turn(r*n/rotations))
+		}
+		if strings.HasSuffix(symmetry, "reflect") {
+			for _, order := range orders[:rotations] {
+				orders = append(orders, mirror(order))
+			}
+		}
+	}
+	return orders, nil
+}
+
+// symmetricTransitions gives the transition with its neighbours in each of the orders,
+// or in every distinct order when orders is nil.
+func symmetricTransitions(tokens []tableToken, orders [][]int) [][]tableToken {
+	cell, neighbours, next := tokens[0], tokens[1:len(tokens)-1], tokens[len(tokens)-1]
+	with := func(turned []tableToken) []tableToken {
+		return append(append([]tableToken{cell}, turned...), next)
+	}
+
+	var all [][]tableToken
+	if orders != nil {
+		for _, order := range orders {
+			turned := make([]tableToken, len(neighbours))
+			for i, j := range order {
+				turned[i] = neighbours[j]
+			}
+			all = append(all, with(turned))
+		}
+		return all
+	}
+
+	// the permutations in lexicographic order, from the sorted one onwards
+	p := append([]tableToken(nil), neighbours...)
+	sort.Slice(p, func(i, j int) bool { return p[i].less(&p[j]) })
+	for {
+		all = append(all, with(append([]tableToken(nil), p...)))
+		i := len(p) - 2
+		for i >= 0 && !p[i].less(&p[i+1]) {
+			i--
+		}
+		if i < 0 {
+			return all
+		}
+		j := len(p) - 1
+		for !p[i].less(&p[j]) {
+			j--
+		}
+		p[i], p[j] = p[j], p[i]
+		for k, l := i+1, len(p)-1; k < l; k, l = k+1, l-1 {
+			p[k], p[l] = p[l], p[k]
+		}
+	}
+}
+
+// bindTransition gives one tableTransition for each choice of states of the bound variables of a transition.
+func bindTransition(tokens []tableToken) []tableTransition {
+	var bound []*tableToken
+	index := make(map[string]int)
+	for i := range tokens {
+		if name := tokens[i].bound; name != "" {
+			if _, ok := index[name]; !ok {
+				index[name] = len(bound)
+				bound = append(bound, &tokens[i])
+			}
+		}
+	}
+
+	var transitions []tableTransition
+	choice := make([]int, len(bound))
+	for {
+		t := tableTransition{inputs: make([]stateSet, len(tokens)-1)}
+		for i, token := range tokens {
+			set := token.states
+			if token.bound != "" {
+				set = stateSet{}
+				set.add(token.values[choice[index[token.bound]]])
+			}
+			if i < len(t.inputs) {
+				t.inputs[i] = set
+				continue
+			}
+			for state := 0; state < 256; state++ {
+				if set.has(state) {
+					t.output = state
+				}
+			}
+		}
+		transitions = append(transitions, t)
+
+		// the next choice of states for the bound variables, counting like an odometer
+		i := 0
+		for ; i < len(bound); i++ {
+			if choice[i]++; choice[i] < len(bound[i].values) {
+				break
+			}
+			choice[i] = 0
+		}
+		if i == len(bound) {
+			return transitions
+		}
+	}
+}
+
+// withoutRepeats leaves out the transitions with the same inputs as one before them, which never apply.
+func withoutRepeats(transitions []tableTransition) []tableTransition {
+	seen := make(map[string]bool)
+	var kept []tableTransition
+	for _, t := range transitions {
+		var key strings.Builder
+		for _, set := range t.inputs {
+			for _, word := range set {
+				key.WriteString(strconv.FormatUint(word, 36))
+				key.WriteByte(',')
+			}
+		}
+		if !seen[key.String()] {
+			seen[key.String()] = true
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// treeBuilder turns the transitions of a @TABLE into a RuleTree. Level l of the tree looks at the cell for l = 0
+// and at neighbour l otherwise, and picks the transitions that still apply. Nodes reached with the same transitions
+// are built once, and nodes with the same children are shared.
+type treeBuilder struct {
+	states, levels int
+	transitions    []tableTransition
+	built          map[string]int32 // node of each level and list of transitions
+	nodes          map[string]int32 // node of each level and children
+	tree           *RuleTree
+}
+
+// build gives the node of the tree at level for the transitions that apply to the states looked at above it.
+// The first transition always applies, as the transitions end with one that keeps the state of each cell.
+func (b *treeBuilder) build(level int, candidates []int32) int32 {
+	key := treeKey(level, candidates)
+	if node, ok := b.built[key]; ok {
+		return node
+	}
+
+	children := make([]int32, b.states)
+	var applying []int32
+	for state := range children {
+		applying = applying[:0]
+		for _, c := range candidates {
+			t := &b.transitions[c]
+			if !t.inputs[level].has(state) {
+				continue
+			}
+			applying = append(applying, c)
+			if t.anyFrom <= level+1 {
+				// this one applies whatever the other cells are, so the ones after it never do
+				break
+			}
+		}
+		if level == b.levels-1 {
+			children[state] = int32(b.transitions[applying[0]].output)
+		} else {
+			children[state] = b.build(level+1, applying)
+		}
+	}
+
+	nodeKey := treeKey(level, children)
+	node, ok := b.nodes[nodeKey]
+	if !ok {
+		node = int32(len(b.tree.Nodes) / b.states)
+		b.tree.Nodes = append(b.tree.Nodes, children...)
+		b.nodes[nodeKey] = node
+	}
+	b.built[key] = node
+	return node
+}
+
+func treeKey(level int, values []int32) string {
+	var key strings.Builder
+	key.WriteString(strconv.Itoa(level))
+	for _, v := range values {
+		key.WriteByte(',')
+		key.WriteString(strconv.Itoa(int(v)))
+	}
+	return key.String()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"os"
 	"strconv"
 	"strings"
@@ -108,6 +109,105 @@ func readAliveCells(path string, width, height int) []util.Cell {
 	return cells
 }
 
+// stepTorus steps a world of a rule that counts the 8 neighbours of each cell on a torus, for tests that check
+// a run turn by turn.
+func stepTorus(rule util.Rule, world [][]byte) [][]byte {
+	immutableWorld := util.MakeTopologyWorld(world, util.Torus)
+	next := util.MakeWorld(len(world[0]), len(world))
+	for y := range next {
+		for x := range next[y] {
+			count := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && immutableWorld(y+dy, x+dx) == 255 {
+						count++
+					}
+				}
+			}
+			next[y][x] = rule.Next(world[y][x], count)
+		}
+	}
+	return next
+}
+
+// untilTurns steps the 64x64 image with a rule for a number of turns, and gives the population and the bounding box
+// of the live cells in each of them.
+func untilTurns(rulestring string, turns int) ([]int, []image.Rectangle) {
+	rule, err := util.ParseRule(rulestring)
+	util.Check(err)
+	world := util.MakeWorld(64, 64)
+	for _, cell := range readAliveCells("check/images/64x64x0.pgm", 64, 64) {
+		world[cell.Y][cell.X] = 255
+	}
+	var populations []int
+	var boxes []image.Rectangle
+	for turn := 0; turn <= turns; turn++ {
+		var box image.Rectangle
+		cells := worldAliveCells(world)
+		for _, c := range cells {
+			box = box.Union(image.Rect(c.X, c.Y, c.X+1, c.Y+1))
+		}
+		populations = append(populations, len(cells))
+		boxes = append(boxes, box)
+		world = stepTorus(rule, world)
+	}
+	return populations, boxes
+}
+
+// worldAliveCells lists the cells of a world in the live state.
+func worldAliveCells(world [][]byte) []util.Cell {
+	var cells []util.Cell
+	for y := range world {
+		for x, value := range world[y] {
+			if value == 255 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// abs gives the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// runResult holds the events of a run the tests look at.
+type runResult struct {
+	final     gol.FinalTurnComplete
+	filenames []string // files output, from the ImageOutputComplete events
+	errs      []gol.IoError
+	cycles    []gol.CycleDetected
+}
+
+// runEvents runs the params to the end and gives the events of the run the tests look at.
+func runEvents(p gol.Params) runResult {
+	var r runResult
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			r.final = e
+		case gol.ImageOutputComplete:
+			r.filenames = append(r.filenames, e.Filename)
+		case gol.IoError:
+			r.errs = append(r.errs, e)
+		case gol.CycleDetected:
+			r.cycles = append(r.cycles, e)
+		}
+	}
+	return r
+}
+
+// finalAlive runs the params to the end and gives the cells alive after the final turn.
+func finalAlive(p gol.Params) []util.Cell {
+	return runEvents(p).final.Alive
+}
+
 type Tester struct {
 	t            *testing.T
 	params       gol.Params
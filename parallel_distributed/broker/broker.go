@@ -25,7 +25,9 @@ var (
 )
 
 type Worker struct {
-	IP string
+	IP     string
+	StartY int
+	EndY   int
 	*rpc.Client
 }
 
@@ -42,18 +44,21 @@ type GameState struct {
 	Workers    int
 	Paused     bool
 	Resume     bool
+	Origin     util.Cell
 }
 
 func (state *GameState) Save(res *stubs.GameResponse) {
 	state.World = res.World
 	state.AliveCells = res.AliveCells
 	state.Turn = res.Turn
+	state.Origin = res.Origin
 }
 
 func (state *GameState) Load(res *stubs.GameResponse) {
 	res.World = state.World
 	res.AliveCells = state.AliveCells
 	res.Turn = state.Turn
+	res.Origin = state.Origin
 }
 
 // split the slice of world
@@ -91,12 +96,16 @@ func haloExchange() {
 	mu.Unlock()
 }
 
-func calculateStateAndCells(res *stubs.GameResponse, turn int) {
+// calculateStateAndCells runs a turn on every worker. The cells beyond the left and right edges come from
+// the current world, as the rows they stand for can belong to any worker, e.g. on a cross-surface.
+// Every worker gets the same noise, so stochastic rules do not depend on how the world is split.
+func calculateStateAndCells(res *stubs.GameResponse, rule util.Rule, topology util.Topology, turn int, noise util.Noise) {
 	var aliveCells []util.Cell
 	var state [][]byte
 
 	for _, w := range workers {
-		pReq := stubs.ProcessRequest{}
+		left, right := topology.EdgeColumns(res.World, w.StartY, w.EndY, rule.Range)
+		pReq := stubs.ProcessRequest{Left: left, Right: right, Noise: noise}
 		pRes := new(stubs.ProcessResponse)
 
 		err := w.Call("Worker.ProcessTurn", pReq, pRes)
@@ -112,7 +121,7 @@ func calculateStateAndCells(res *stubs.GameResponse, turn int) {
 	res.Turn = turn
 }
 
-func initialise(req stubs.GameRequest, res *stubs.GameResponse, turn int) {
+func initialise(req stubs.GameRequest, res *stubs.GameResponse, rule util.Rule, topology util.Topology, turn int) {
 	p := req.P
 	world := req.World
 
@@ -143,7 +152,11 @@ func initialise(req stubs.GameRequest, res *stubs.GameResponse, turn int) {
 			NextAddr:     workers[nextIndex].IP,
 			Workers:      len(workers),
 			IP:           worker.IP,
+			Rule:         rule,
+			Topology:     topology,
 		}
+		workers[i].StartY = startY
+		workers[i].EndY = endY
 
 		fmt.Println(len(workers))
 		bRes := new(stubs.BrokerResponse)
@@ -160,6 +173,18 @@ func initialise(req stubs.GameRequest, res *stubs.GameResponse, turn int) {
 	res.Turn = turn
 }
 
+// growUniverse grows the world of an unbounded universe when its cells approach the edges,
+// and splits the grown world between the workers again.
+func growUniverse(res *stubs.GameResponse, p *stubs.Params, rule util.Rule, topology util.Topology, turn int) {
+	world, shift, ok := util.GrowWorld(res.World, rule.Range)
+	if !ok {
+		return
+	}
+	p.ImageWidth, p.ImageHeight = len(world[0]), len(world)
+	res.Origin = util.Cell{X: res.Origin.X - shift.X, Y: res.Origin.Y - shift.Y}
+	initialise(stubs.GameRequest{World: world, P: *p, Turn: turn}, res, rule, topology, turn)
+}
+
 func CloseServer() {
 	mu.Lock()
 	for _, w := range workers {
@@ -244,15 +269,43 @@ func (b *Broker) RunGol(req stubs.GameRequest, res *stubs.GameResponse) (err err
 	p := req.P
 	turn := req.Turn
 
+	rule, err := util.ParseRule(p.Rule)
+	if err != nil {
+		return err
+	}
+
+	topology, err := util.ParseTopology(p.Topology)
+	if err == nil {
+		err = topology.Check(&rule)
+	}
+	if err == nil {
+		err = rule.CheckSize(topology, p.ImageWidth, p.ImageHeight)
+	}
+	if err == nil && rule.Cubic {
+		// the workers only exchange the rows of a 2D world
+		err = fmt.Errorf("3D rule %v only runs in the parallel version", rule)
+	}
+	if err != nil {
+		return err
+	}
+
 	n := len(workers)
+	if n > 0 && p.ImageHeight/n < rule.Range {
+		return fmt.Errorf("rule %v needs at least %v rows per worker for the halo exchange", rule, rule.Range)
+	}
 
 	// Extension: Fault Tolerance
 	stateMu.Lock()
 	if gameState.Resume {
 		turn = gameState.Turn
+		if topology == util.Unbounded {
+			// the world may have grown since the game started
+			p.ImageWidth, p.ImageHeight = len(gameState.World[0]), len(gameState.World)
+			res.Origin = gameState.Origin
+		}
 		req = stubs.GameRequest{
 			World: gameState.World,
-			P:     req.P,
+			P:     p,
 			Turn:  turn,
 		}
 		gameState.Resume = false
@@ -260,7 +313,10 @@ func (b *Broker) RunGol(req stubs.GameRequest, res *stubs.GameResponse) (err err
 	}
 	stateMu.Unlock()
 
-	initialise(req, res, turn)
+	initialise(req, res, rule, topology, turn)
+	if topology == util.Unbounded {
+		res.AliveCells = util.Translate(res.AliveCells, res.Origin)
+	}
 
 	stateMu.Lock()
 	gameState.Save(res)
@@ -282,11 +338,15 @@ func (b *Broker) RunGol(req stubs.GameRequest, res *stubs.GameResponse) (err err
 		default:
 			stateMu.Lock()
 			if !gameState.Paused {
+				if topology == util.Unbounded {
+					growUniverse(res, &p, rule, topology, turn)
+				}
 				turn++
-				if n != 1 {
-					haloExchange()
+				haloExchange()
+				calculateStateAndCells(res, rule, topology, turn, util.Noise{Seed: p.Seed, Turn: turn, Origin: res.Origin})
+				if topology == util.Unbounded {
+					res.AliveCells = util.Translate(res.AliveCells, res.Origin)
 				}
-				calculateStateAndCells(res, turn)
 				gameState.Save(res)
 			}
 			stateMu.Unlock()
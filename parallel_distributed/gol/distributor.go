@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"image"
 	"net/rpc"
 	"time"
 	"uk.ac.bris.cs/gameoflife/stubs"
@@ -15,6 +16,9 @@ type distributorChannels struct {
 	ioFilename chan<- string
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
+	ioRegion   chan<- image.Rectangle
+	ioCells    chan<- []util.Cell
+	ioError    <-chan error
 	keyPressCh <-chan rune
 }
 
@@ -23,33 +27,91 @@ func checkIoIdle(c distributorChannels) {
 	<-c.ioIdle
 }
 
-func loadWorld(p stubs.Params, c distributorChannels) [][]byte {
+// loadWorld reads the image of the params, or gives the error that kept the io goroutine from reading it.
+func loadWorld(p stubs.Params, c distributorChannels) ([][]byte, error) {
 	inFileName := fmt.Sprintf("%vx%v", p.ImageWidth, p.ImageHeight)
+	if p.Input != "" {
+		inFileName = p.Input
+	}
 	checkIoIdle(c)
 	// start reading the pgm file if io is idle
 	c.ioCommand <- ioInput
 	c.ioFilename <- inFileName
+	if err := <-c.ioError; err != nil {
+		return nil, err
+	}
 	world := util.MakeWorld(p.ImageWidth, p.ImageHeight)
 	for _, w := range world {
 		for i := range w {
 			w[i] = <-c.ioInput
 		}
 	}
-	return world
+	return world, nil
 }
 
-func exportWorld(p stubs.Params, c distributorChannels, finishWorld [][]byte, turn int) {
-	checkIoIdle(c)
-	outFileName := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn)
-	c.ioCommand <- ioOutput
-	c.ioFilename <- outFileName
-	for _, w := range finishWorld {
-		for i := range w {
-			c.ioOutput <- w[i]
+func exportWorld(p stubs.Params, c distributorChannels, finishWorld [][]byte, origin util.Cell, turn int) {
+	if topology, _ := util.ParseTopology(p.Topology); topology == util.Unbounded {
+		exportRegion(p, c, finishWorld, origin, turn)
+		return
+	}
+	outputImage(p, c, fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn), finishWorld, turn, nil)
+}
+
+// exportRegion is exportWorld for an unbounded universe. It only outputs the bounding box of the cells
+// that are not dead, and records where it lies in the universe.
+func exportRegion(p stubs.Params, c distributorChannels, finishWorld [][]byte, origin util.Cell, turn int) {
+	world, box := util.CropWorld(finishWorld)
+	region := box.Add(image.Pt(origin.X, origin.Y))
+	outFileName := fmt.Sprintf("%vx%vx%v", region.Dx(), region.Dy(), turn)
+	outputImage(p, c, outFileName, world, turn, &region)
+}
+
+// outputImage has the io goroutine write a world to a file in each output format of the params, and reports
+// each file. A world of an unbounded universe is given the region of the universe it lies in, and others nil.
+func outputImage(p stubs.Params, c distributorChannels, name string, world [][]byte, turn int, region *image.Rectangle) {
+	for _, format := range outputFormats(p) {
+		outFileName := outputName(name, format)
+		if format == LifeFormat {
+			var origin util.Cell
+			if region != nil {
+				origin = util.Cell{X: region.Min.X, Y: region.Min.Y}
+			}
+			outputCells(c, outFileName, util.LiveCells(world, origin), turn)
+			continue
 		}
+		checkIoIdle(c)
+		if region == nil {
+			c.ioCommand <- ioOutput
+			c.ioFilename <- outFileName
+		} else {
+			c.ioCommand <- ioOutputRegion
+			c.ioFilename <- outFileName
+			c.ioRegion <- *region
+		}
+		for _, w := range world {
+			for i := range w {
+				c.ioOutput <- w[i]
+			}
+		}
+		// The io goroutine answers once all bytes of the world have been written, before ImageOutputComplete is sent
+		if err := <-c.ioError; err != nil {
+			c.events <- IoError{turn, err}
+			continue
+		}
+		c.events <- ImageOutputComplete{turn, outFileName}
 	}
-	// Make sure all byte in the world has been passed to the output channel before sending ImageOutputComplete
+}
+
+// outputCells has the io goroutine write a list of live cells to a Life 1.06 file, and reports the file.
+func outputCells(c distributorChannels, outFileName string, cells []util.Cell, turn int) {
 	checkIoIdle(c)
+	c.ioCommand <- ioOutputCells
+	c.ioFilename <- outFileName
+	c.ioCells <- cells
+	if err := <-c.ioError; err != nil {
+		c.events <- IoError{turn, err}
+		return
+	}
 	c.events <- ImageOutputComplete{turn, outFileName}
 }
 
@@ -62,9 +124,7 @@ func ManageKeyPress(c distributorChannels, p stubs.Params, client *rpc.Client) {
 			switch k {
 			case 's':
 				_ = client.Call(stubs.SaveWorld, keyReq, res)
-				outFileName := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, res.Turn)
-				exportWorld(p, c, res.World, res.Turn)
-				c.events <- ImageOutputComplete{CompletedTurns: res.Turn, Filename: outFileName}
+				exportWorld(p, c, res.World, res.Origin, res.Turn)
 			case 'q':
 				_ = client.Call(stubs.ClientQuit, keyReq, res)
 			case 'k':
@@ -113,7 +173,15 @@ func distributor(p stubs.Params, c distributorChannels) {
 	client, err := rpc.Dial("tcp", "127.0.0.1:8030")
 	util.Check(err)
 
-	inputWorld := loadWorld(p, c)
+	inputWorld, err := loadWorld(p, c)
+	if err != nil {
+		// there is nothing to run without the first world
+		c.events <- IoError{0, err}
+		c.events <- StateChange{0, Quitting}
+		close(c.events)
+		_ = client.Close()
+		return
+	}
 	turn := 0
 	req := stubs.GameRequest{World: inputWorld, P: p, Turn: turn}
 	res := new(stubs.GameResponse)
@@ -125,6 +193,14 @@ func distributor(p stubs.Params, c distributorChannels) {
 	<-runGol.Done
 	quitAliveCells <- true
 
+	if runGol.Error != nil {
+		// The broker refused to run the game, e.g. because the rule does not suit the number of workers.
+		fmt.Println(runGol.Error)
+		c.events <- StateChange{turn, Quitting}
+		close(c.events)
+		return
+	}
+
 	util.Check(err)
 	if res.Kill {
 		closeReq := stubs.CloseRequest{}
@@ -136,7 +212,7 @@ func distributor(p stubs.Params, c distributorChannels) {
 	c.events <- FinalTurnComplete{CompletedTurns: res.Turn, Alive: res.AliveCells}
 
 	// output pgm
-	exportWorld(p, c, res.World, res.Turn)
+	exportWorld(p, c, res.World, res.Origin, res.Turn)
 	c.events <- StateChange{res.Turn, Quitting}
 
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
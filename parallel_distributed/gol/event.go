@@ -15,6 +15,7 @@ type Event interface {
 }
 
 // `AliveCellsCount` is an Event notifying the user about the number of currently alive cells.
+// Only cells in the live state are counted, dying cells of Generations rules are not.
 // This Event should be sent every 2s.
 type AliveCellsCount struct { // implements Event
 	CompletedTurns int
@@ -28,6 +29,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// `IoError` is an Event notifying the user that a file could not be read or written.
+// A run whose input cannot be read sends it before quitting, and a run whose image cannot be output goes on.
+type IoError struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -57,9 +65,14 @@ type CellFlipped struct { // implements Event
 // You can send many times of `CellsFlipped` event in a turn, i.e., each worker could send `CellsFlipped`.
 // **Please be careful not to send `CellFlipped` and `CellsFlipped` at the same time, as they may conflict.**
 // Choose one of them.
+// For Generations rules a cell can change state without flipping between dead and alive, e.g. when it decays.
+// `Cells` then lists every cell whose state changed and `Values` holds the new grey level of each of them.
+// `Values` is nil for rules with two states, where every change is a flip.
+// In an unbounded universe cells are given in universe coordinates, which can be negative or lie beyond the image.
 type CellsFlipped struct { // implements Event
 	CompletedTurns int
 	Cells          []util.Cell
+	Values         []byte
 }
 
 // `TurnComplete` is an Event notifying the GUI about turn completion.
@@ -72,6 +85,7 @@ type TurnComplete struct { // implements Event
 // `FinalTurnComplete` is an Event notifying the testing framework about the new world state after execution finished.
 // The data included with this Event is used directly by the tests.
 // SDL closes the window when this Event is sent.
+// `Alive` uses universe coordinates in an unbounded universe, like `CellsFlipped`.
 type FinalTurnComplete struct {
 	CompletedTurns int
 	Alive          []util.Cell
@@ -116,6 +130,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event IoError) String() string {
+	return fmt.Sprintf("File Error: %v", event.Err)
+}
+
+func (event IoError) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return ""
 }
@@ -1,6 +1,10 @@
 package gol
 
-import "uk.ac.bris.cs/gameoflife/stubs"
+import (
+	"image"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p stubs.Params, events chan<- Event, keyPresses <-chan rune) {
@@ -9,6 +13,9 @@ func Run(p stubs.Params, events chan<- Event, keyPresses <-chan rune) {
 	fileCh := make(chan string)
 	outputCh := make(chan uint8)
 	inputCh := make(chan uint8)
+	regionCh := make(chan image.Rectangle)
+	cellsCh := make(chan []util.Cell)
+	errCh := make(chan error)
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
@@ -19,6 +26,9 @@ func Run(p stubs.Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: fileCh,
 		output:   outputCh,
 		input:    inputCh,
+		region:   regionCh,
+		cells:    cellsCh,
+		err:      errCh,
 	}
 	go startIo(p, ioChannels)
 
@@ -29,6 +39,9 @@ func Run(p stubs.Params, events chan<- Event, keyPresses <-chan rune) {
 		ioFilename: fileCh,
 		ioOutput:   outputCh,
 		ioInput:    inputCh,
+		ioRegion:   regionCh,
+		ioCells:    cellsCh,
+		ioError:    errCh,
 		keyPressCh: keyPresses,
 	}
 	distributor(p, distributorChannels)
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
 	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -27,13 +30,13 @@ func main() {
 		&params.ImageWidth,
 		"w",
 		512,
-		"Specify the width of the image. Defaults to 512.")
+		"Specify the width of the image. Defaults to 512, or the width of the -input file.")
 
 	flag.IntVar(
 		&params.ImageHeight,
 		"h",
 		512,
-		"Specify the height of the image. Defaults to 512.")
+		"Specify the height of the image. Defaults to 512, or the height of the -input file.")
 
 	flag.IntVar(
 		&params.Turns,
@@ -41,6 +44,71 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.StringVar(
+		&params.Rule,
+		"rule",
+		util.DefaultRule,
+		"Specify the rule in B/S notation, e.g. B36/S23 for HighLife, or the path of a Golly .rule file. Defaults to B3/S23.")
+
+	flag.StringVar(
+		&params.Topology,
+		"topology",
+		"torus",
+		"Specify how the edges of the world are joined: torus, plane, alive, hcylinder, vcylinder, klein, cross or unbounded. Defaults to torus.")
+
+	flag.Uint64Var(
+		&params.Seed,
+		"seed",
+		0,
+		"Specify the seed of the random numbers of rules with chances, e.g. B3(0.95)/S23. Defaults to 0.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify a pattern or image to start from instead of images/WxH.pgm: .rle, .cells, Life 1.06 .lif, .pbm or .pgm. The world takes its size and an RLE file its rule, unless -w, -h or -rule are given.")
+
+	offset := flag.String(
+		"offset",
+		"",
+		"Specify the cell the top left of the input pattern is placed at as x,y. Defaults to placing it in the middle.")
+
+	flag.StringVar(
+		&params.Output,
+		"output",
+		gol.PgmFormat,
+		"Specify the comma-separated formats images are output in: pgm, pbm, png, rle, cells and lif. Defaults to pgm.")
+
+	flag.BoolVar(
+		&params.Plain,
+		"plain",
+		false,
+		"Output pbm and pgm images in their plain ASCII forms. Defaults to false.")
+
+	flag.IntVar(
+		&params.Threshold,
+		"threshold",
+		gol.DefaultThreshold,
+		"Specify the grey level from which the pixels of an input image are live cells for two-state rules. Defaults to 128.")
+
+	flag.IntVar(
+		&params.Scale,
+		"scale",
+		1,
+		"Specify the side of the square of pixels each cell is drawn as in png images. Defaults to 1.")
+
+	flag.StringVar(
+		&params.Palette,
+		"palette",
+		util.GreyPalette,
+		"Specify the colours of png images: grey, inverse, or hex colours for the dead, live and dying states, e.g. #ffffff,#000080. Defaults to grey.")
+
+	flag.StringVar(
+		&params.OutDir,
+		"outdir",
+		gol.DefaultOutDir,
+		"Specify the directory images are output to. Defaults to out.")
+
 	headless := flag.Bool(
 		"headless",
 		false,
@@ -48,10 +116,80 @@ func main() {
 
 	flag.Parse()
 
+	if *offset != "" {
+		params.Offset = new(util.Cell)
+		if _, err := fmt.Sscanf(*offset, "%d,%d", &params.Offset.X, &params.Offset.Y); err != nil {
+			fmt.Println("invalid offset", *offset, "expected x,y")
+			os.Exit(1)
+		}
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if params.Input != "" {
+		// the io goroutine pads or crops the input to the size given with -w and -h
+		width, height, err := util.InputSize(params.Input)
+		if err == nil && (width == 0 || height == 0) {
+			err = fmt.Errorf("input %v has no cells to size the world by", params.Input)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !given["w"] {
+			params.ImageWidth = width
+		}
+		if !given["h"] {
+			params.ImageHeight = height
+		}
+	}
+	if strings.EqualFold(filepath.Ext(params.Input), ".rle") {
+		// only RLE files give a rule
+		pattern, err := util.LoadPattern(params.Input)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !given["rule"] && pattern.Rule != "" {
+			params.Rule = pattern.Rule
+		}
+	}
+
+	rule, err := util.ParseRule(params.Rule)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	topology, err := util.ParseTopology(params.Topology)
+	if err == nil {
+		err = topology.Check(&rule)
+	}
+	if err == nil {
+		err = rule.CheckSize(topology, params.ImageWidth, params.ImageHeight)
+	}
+	if err == nil && rule.Cubic {
+		// the workers only exchange the rows of a 2D world
+		err = fmt.Errorf("3D rule %v only runs in the parallel version", rule)
+	}
+	if err == nil {
+		err = gol.CheckFiles(params)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	fmt.Printf("%-10v %v\n", "Threads", params.Threads)
 	fmt.Printf("%-10v %v\n", "Width", params.ImageWidth)
 	fmt.Printf("%-10v %v\n", "Height", params.ImageHeight)
 	fmt.Printf("%-10v %v\n", "Turns", params.Turns)
+	fmt.Printf("%-10v %v\n", "Rule", params.Rule)
+	fmt.Printf("%-10v %v\n", "Topology", params.Topology)
+	fmt.Printf("%-10v %v\n", "Seed", params.Seed)
+	if params.Input != "" {
+		fmt.Printf("%-10v %v\n", "Input", params.Input)
+	}
+	fmt.Printf("%-10v %v\n", "Output", params.Output)
+	fmt.Printf("%-10v %v\n", "Outdir", params.OutDir)
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
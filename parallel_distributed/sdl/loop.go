@@ -12,12 +12,23 @@ import (
 const FPS = 60
 
 func Run(p stubs.Params, events <-chan gol.Event, keyPresses chan<- rune) {
-	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	rule, err := util.ParseRule(p.Rule)
+	w := NewGridWindow(int32(p.ImageWidth), int32(p.ImageHeight), rule.Grid)
 	defer w.Destroy()
 	dirty := false
 	refreshTicker := time.NewTicker(time.Second / time.Duration(FPS))
 	avgTurns := util.NewAvgTurns()
 
+	if err == nil && rule.Colours != nil {
+		w.SetColours(rule)
+	}
+
+	// An unbounded universe can leave the window, which then follows the pattern.
+	var follow *view
+	if topology, _ := util.ParseTopology(p.Topology); topology == util.Unbounded {
+		follow = newView()
+	}
+
 sdl:
 	for {
 		select {
@@ -55,10 +66,27 @@ sdl:
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
 			case gol.CellsFlipped:
-				for _, cell := range e.Cells {
-					w.FlipPixel(cell.X, cell.Y)
+				if follow != nil {
+					for i, cell := range e.Cells {
+						if e.Values != nil {
+							follow.set(w, cell, e.Values[i])
+						} else {
+							follow.flip(w, cell)
+						}
+					}
+				} else if e.Values != nil {
+					for i, cell := range e.Cells {
+						w.SetPixelValue(cell.X, cell.Y, e.Values[i])
+					}
+				} else {
+					for _, cell := range e.Cells {
+						w.FlipPixel(cell.X, cell.Y)
+					}
 				}
 			case gol.TurnComplete:
+				if follow != nil {
+					follow.follow(w)
+				}
 				dirty = true
 			case gol.AliveCellsCount:
 				fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, avgTurns.Get(event.GetCompletedTurns()))
@@ -66,6 +94,8 @@ sdl:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.ImageOutputComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.IoError:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.StateChange:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 				if e.NewState == gol.Quitting {
@@ -86,6 +116,8 @@ func RunHeadless(events <-chan gol.Event) {
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), "Final Turn Complete")
 		case gol.ImageOutputComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.IoError:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 		case gol.StateChange:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			if e.NewState == gol.Quitting {
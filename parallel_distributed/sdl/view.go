@@ -0,0 +1,66 @@
+package sdl
+
+import (
+	"image"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// view shows the part of an unbounded universe around its pattern, as the window has the size of the initial image.
+// It remembers every cell that is not dead, so it can redraw the window when the pattern moves away.
+type view struct {
+	origin util.Cell // universe cell at the top left of the window
+	cells  map[util.Cell]byte
+	lost   bool // a cell changed outside of the window since the last redraw
+}
+
+func newView() *view {
+	return &view{cells: make(map[util.Cell]byte)}
+}
+
+// set changes the value of a universe cell, drawing it when it is inside the window.
+func (v *view) set(w *Window, cell util.Cell, value byte) {
+	if value == 0 {
+		delete(v.cells, cell)
+	} else {
+		v.cells[cell] = value
+	}
+	x, y := cell.X-v.origin.X, cell.Y-v.origin.Y
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+		v.lost = true
+		return
+	}
+	w.SetPixelValue(x, y, value)
+}
+
+// flip flips a universe cell between dead and alive.
+func (v *view) flip(w *Window, cell util.Cell) {
+	if v.cells[cell] == 0 {
+		v.set(w, cell, 0xFF)
+	} else {
+		v.set(w, cell, 0)
+	}
+}
+
+// follow centres the window on the bounding box of the pattern and redraws it, if part of the pattern
+// has gone out of the window.
+func (v *view) follow(w *Window) {
+	if !v.lost {
+		return
+	}
+	v.lost = false
+	var box image.Rectangle
+	for cell := range v.cells {
+		box = box.Union(image.Rect(cell.X, cell.Y, cell.X+1, cell.Y+1))
+	}
+	centre := box.Min.Add(box.Max).Div(2)
+	// the origin stays even, so that hexagonal and triangular cells keep their shape
+	v.origin = util.Cell{X: (centre.X - int(w.Width)/2) &^ 1, Y: (centre.Y - int(w.Height)/2) &^ 1}
+
+	w.ClearPixels()
+	for cell, value := range v.cells {
+		x, y := cell.X-v.origin.X, cell.Y-v.origin.Y
+		if x >= 0 && y >= 0 && x < int(w.Width) && y < int(w.Height) {
+			w.SetPixelValue(x, y, value)
+		}
+	}
+}
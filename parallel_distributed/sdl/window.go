@@ -2,6 +2,7 @@ package sdl
 
 import (
 	"fmt"
+	"math"
 	"unsafe"
 	
 	"github.com/veandco/go-sdl2/sdl"
@@ -14,34 +15,188 @@ type Window struct {
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+	colours       *[256][3]byte // red, green and blue of each cell value, or nil for shades of grey
+
+	// Hexagonal and triangular cells are drawn on a larger texture: shape gives the cell of pixels
+	// at each of its pixels, or -1 for the gaps between cells. Both are nil for square cells.
+	shape       []int32
+	screen      []byte
+	screenWidth int32
+	// A 3D world keeps its slices below each other in pixels, and the window shows one of them at a time.
+	depth, slice int32
 }
 
+// maxWindowWidth is the widest a window showing hexagonal or triangular cells opens, beyond which it is scaled down.
+const maxWindowWidth = 1024
+
 func filterEvent(e sdl.Event, userdata interface{}) bool {
 	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
 }
 
 func NewWindow(width, height int32) *Window {
+	return NewGridWindow(width, height, util.SquareGrid)
+}
+
+// NewGridWindow opens a window showing width x height cells of the grid. Square cells are a pixel each,
+// hexagonal and triangular cells are drawn as hexagons and triangles a few pixels across.
+func NewGridWindow(width, height int32, grid util.Grid) *Window {
+	textureWidth, textureHeight := width, height
+	var shape []int32
+	if grid != util.SquareGrid {
+		shape, textureWidth, textureHeight = gridShape(grid, int(width), int(height))
+	}
+	windowWidth, windowHeight := textureWidth, textureHeight
+	if grid != util.SquareGrid && windowWidth > maxWindowWidth {
+		windowWidth, windowHeight = maxWindowWidth, textureHeight*maxWindowWidth/textureWidth
+	}
+
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, windowWidth, windowHeight, sdl.WINDOW_SHOWN)
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
+	err = renderer.SetLogicalSize(textureWidth, textureHeight)
 	util.Check(err)
-	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, textureWidth, textureHeight)
 	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
-	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
+	w := &Window{
+		Width:    width,
+		Height:   height,
+		window:   window,
+		renderer: renderer,
+		texture:  texture,
+		pixels:   make([]byte, width*height*4),
+		depth:    1,
+	}
+	if shape != nil {
+		w.shape = shape
+		w.screen = make([]byte, len(shape)*4)
+		w.screenWidth = textureWidth
+	}
+	return w
+}
+
+// gridShape gives the cell drawn at each pixel of a texture showing width x height hexagonal or triangular cells,
+// with the size of the texture. Cells are as large as fits the texture in about 2048 pixels, and at least
+// 4 pixels across. The last pixels of a cell on the right and at the bottom are left as gaps, so that cells
+// can be told apart.
+func gridShape(grid util.Grid, width, height int) ([]int32, int32, int32) {
+	scale := 2048 / width
+	if scale < 4 {
+		scale = 4
 	}
+	if scale > 32 {
+		scale = 32
+	}
+	size := float64(scale)
+
+	// cellAt gives the cell a point of the texture lies in, which may be outside of the world
+	var cellAt func(px, py float64) (int, int)
+	var textureWidth, textureHeight int
+	switch grid {
+	case util.HexagonalGrid:
+		// pointy-topped hexagons size pixels across, whose centres lie rowHeight apart
+		rowHeight := size * math.Sqrt(3) / 2
+		textureWidth = int(math.Ceil((float64(width) + 0.5) * size))
+		top := size / math.Sqrt(3)
+		textureHeight = int(math.Ceil(float64(height-1)*rowHeight + 2*top))
+		cellAt = func(px, py float64) (int, int) {
+			// every point lies in the hexagon of the nearest centre
+			row := int(math.Floor((py - top) / rowHeight))
+			bestX, bestY, best := 0, 0, math.Inf(1)
+			for y := row; y <= row+1; y++ {
+				shift := 0.5 * float64(y&1)
+				x := int(math.Floor(px/size - shift - 0.5))
+				for cx := x; cx <= x+1; cx++ {
+					dx := px - (float64(cx)+0.5+shift)*size
+					dy := py - (top + float64(y)*rowHeight)
+					if d := dx*dx + dy*dy; d < best {
+						bestX, bestY, best = cx, y, d
+					}
+				}
+			}
+			return bestX, bestY
+		}
+	default:
+		// triangles 2*size pixels across, each starting half a triangle to the right of the one before
+		rowHeight := 2 * size * math.Sqrt(3) / 2
+		textureWidth = int(math.Ceil(float64(width+1) * size))
+		textureHeight = int(math.Ceil(float64(height) * rowHeight))
+		cellAt = func(px, py float64) (int, int) {
+			y := int(math.Floor(py / rowHeight))
+			down := py/rowHeight - float64(y)
+			x := int(math.Floor(px / size))
+			across := px/size - float64(x)
+			// triangle x starts at the left of the column, triangle x-1 ends at its right,
+			// and the edge between them goes up to the right if triangle x points up
+			if (x+y)&1 == 0 {
+				if across < 1-down {
+					x--
+				}
+			} else if across < down {
+				x--
+			}
+			return x, y
+		}
+	}
+
+	shape := make([]int32, textureWidth*textureHeight)
+	for py := 0; py < textureHeight; py++ {
+		for px := 0; px < textureWidth; px++ {
+			x, y := cellAt(float64(px)+0.5, float64(py)+0.5)
+			shape[py*textureWidth+px] = -1
+			if x >= 0 && y >= 0 && x < width && y < height {
+				shape[py*textureWidth+px] = int32(y*width + x)
+			}
+		}
+	}
+	for py := 0; py < textureHeight; py++ {
+		for px := 0; px < textureWidth; px++ {
+			i := py*textureWidth + px
+			if px+1 < textureWidth && shape[i+1] != shape[i] || py+1 < textureHeight && shape[i+textureWidth] != shape[i] {
+				shape[i] = -1
+			}
+		}
+	}
+	return shape, int32(textureWidth), int32(textureHeight)
+}
+
+// SetColours draws each cell in the colour its rule gives its state, for rules loaded from a rule file with @COLORS.
+func (w *Window) SetColours(rule util.Rule) {
+	w.colours = new([256][3]byte)
+	for value := range w.colours {
+		w.colours[value] = rule.Colours[rule.State(byte(value))]
+	}
+}
+
+// SetDepth makes room for the cells of depth slices, which the CellsFlipped events give stacked below each other:
+// cell (x, y) of slice z is at (x, z*Height+y). The window starts on the middle slice.
+func (w *Window) SetDepth(depth int32) {
+	w.depth = depth
+	w.pixels = make([]byte, w.Width*w.Height*depth*4)
+	w.ShowSlice(depth / 2)
+}
+
+// ShowSlice picks which slice of a 3D world the window shows, keeping it within the world,
+// and names it in the title of the window.
+func (w *Window) ShowSlice(z int32) {
+	if z < 0 {
+		z = 0
+	}
+	if z >= w.depth {
+		z = w.depth - 1
+	}
+	w.slice = z
+	w.window.SetTitle(fmt.Sprintf("GOL GUI - slice %d of %d", z, w.depth))
+}
+
+// Slice gives the slice of a 3D world the window shows.
+func (w *Window) Slice() int32 {
+	return w.slice
 }
 
 func (w *Window) Destroy() {
@@ -55,7 +210,21 @@ func (w *Window) Destroy() {
 }
 
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, unsafe.Pointer(&w.pixels[0]), int(w.Width*4))
+	var err error
+	pixels := w.pixels[4*w.slice*w.Width*w.Height:]
+	if w.shape != nil {
+		var gap [4]byte
+		for i, cell := range w.shape {
+			if cell < 0 {
+				copy(w.screen[4*i:4*i+4], gap[:])
+			} else {
+				copy(w.screen[4*i:4*i+4], pixels[4*cell:4*cell+4])
+			}
+		}
+		err = w.texture.Update(nil, unsafe.Pointer(&w.screen[0]), int(w.screenWidth*4))
+	} else {
+		err = w.texture.Update(nil, unsafe.Pointer(&pixels[0]), int(w.Width*4))
+	}
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
@@ -76,12 +245,42 @@ func (w *Window) SetPixel(x, y int) {
 	w.pixels[4*(y*width+x)+3] = 0xFF
 }
 
+// SetPixelValue draws a cell as a shade of grey, used for the dying states of Generations rules.
+func (w *Window) SetPixelValue(x, y int, value byte) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height*w.depth) {
+		panic(fmt.Sprintf("CellsFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+
+	alpha := byte(0xFF)
+	if value == 0 {
+		alpha = 0
+	}
+	red, green, blue := value, value, value
+	if w.colours != nil {
+		red, green, blue = w.colours[value][0], w.colours[value][1], w.colours[value][2]
+	}
+	width := int(w.Width)
+	w.pixels[4*(y*width+x)+0] = blue
+	w.pixels[4*(y*width+x)+1] = green
+	w.pixels[4*(y*width+x)+2] = red
+	w.pixels[4*(y*width+x)+3] = alpha
+}
+
 func (w *Window) FlipPixel(x, y int) {
-	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height*w.depth) {
 		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
 	}
 
 	width := int(w.Width)
+	if w.colours != nil {
+		// dead cells are the only ones drawn transparent
+		if w.pixels[4*(y*width+x)+3] == 0 {
+			w.SetPixelValue(x, y, 0xFF)
+		} else {
+			w.SetPixelValue(x, y, 0)
+		}
+		return
+	}
 	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
 	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
 	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
@@ -90,7 +289,7 @@ func (w *Window) FlipPixel(x, y int) {
 
 func (w *Window) CountPixels() int {
 	count := 0
-	for i := 0; i < int(w.Width) * int(w.Height) * 4; i += 4 {
+	for i := 0; i < len(w.pixels); i += 4 {
 		if w.pixels[i] == 0xFF {
 			count++
 		}
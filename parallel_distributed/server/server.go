@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math/bits"
 	"net"
 	"net/rpc"
 	"os"
@@ -21,54 +22,79 @@ const live byte = 255
 
 var QuitServer = make(chan bool)
 
-func CalculateLiveNeighbour(p stubs.Params, x, y, maxY int, immutableWorld func(int, int) byte) int {
+// CalculateLiveNeighbour counts the live neighbours of a cell. Rows wrap around the part of the world
+// the worker holds, whose halo rows are thrown away, and columns past the edges come from makeWorkerWorld.
+// The grid decides which cells are neighbours from the row of the cell in the whole world, offSetY rows down.
+func CalculateLiveNeighbour(p stubs.Params, grid util.Grid, x, y, maxY, offSetY int, immutableWorld func(int, int) byte) int {
 	counter := 0
-	relativeDir := [8][2]int{
-		{0, 1}, {0, -1},
-		{1, 0}, {-1, 0},
-		{-1, -1}, {-1, 1},
-		{1, 1}, {1, -1},
+	for _, dir := range grid.Neighbours(x, y+offSetY) {
+		ny := (dir.Y + maxY + y) % maxY
+		nx := dir.X + x
+
+		if immutableWorld(ny, nx) == live {
+			counter++
+		}
 	}
+	return counter
+}
 
-	for _, dir := range relativeDir {
+// neighbourhoodDir lists the neighbours clockwise from the north-west corner, in the bit order of util.HenselLetter.
+var neighbourhoodDir = [8][2]int{
+	{-1, -1}, {0, -1}, {1, -1}, {1, 0},
+	{1, 1}, {0, 1}, {-1, 1}, {-1, 0},
+}
+
+// CalculateNeighbourhood gives the bitmask of the live neighbours of a cell, used by isotropic rules.
+func CalculateNeighbourhood(p stubs.Params, x, y, maxY int, immutableWorld func(int, int) byte) uint8 {
+	var neighbourhood uint8
+	for i, dir := range neighbourhoodDir {
 		ny := (dir[1] + maxY + y) % maxY
-		nx := (dir[0] + p.ImageWidth + x) % p.ImageWidth
+		nx := dir[0] + x
 
 		if immutableWorld(ny, nx) == live {
-			counter++
+			neighbourhood |= 1 << i
 		}
 	}
-	return counter
+	return neighbourhood
 }
 
-func CalculateNextState(p stubs.Params, startY, endY, maxY int, immutableWorld func(int, int) byte) [][]byte {
-	// TODO : Implement a parallel version for workers
-	newWorld := util.MakeWorld(p.ImageWidth, endY-startY)
+// CalculateNextState steps the rows startY to endY-1 of the world into newWorld, which holds just those rows,
+// and appends the cells that are live afterwards to cells in the same sweep, offSetY rows down.
+// sums is only used by Larger than Life rules, and noise by stochastic rules and by block rules for its turn.
+func CalculateNextState(p stubs.Params, rule util.Rule, noise util.Noise, startY, endY, maxY, offSetY int, immutableWorld func(int, int) byte, newWorld [][]byte, sums *rowSums, cells []util.Cell) []util.Cell {
+	if rule.LargerThanLife() {
+		return CalculateExtendedNextState(p, rule, startY, endY, maxY, offSetY, immutableWorld, newWorld, sums, cells)
+	}
+	wrappedWorld := func(y, x int) byte {
+		return immutableWorld((y+maxY)%maxY, x)
+	}
+	// block rules find the blocks from the rows of the whole world
+	globalWorld := func(y, x int) byte {
+		return wrappedWorld(y-offSetY, x)
+	}
 	for y := startY; y < endY; y++ {
 		j := y - startY // adjust the column
 		for x := 0; x < p.ImageWidth; x++ {
-			counter := CalculateLiveNeighbour(p, x, y, maxY, immutableWorld)
-			if immutableWorld(y, x) == live {
-				if counter < 2 || counter > 3 {
-					newWorld[j][x] = dead
-				} else {
-					newWorld[j][x] = live
+			var next byte
+			if rule.Block != nil {
+				next = rule.NextBlock(x, y+offSetY, noise.Turn, globalWorld)
+			} else if rule.Tree != nil {
+				next = rule.NextTable(x, y, wrappedWorld)
+			} else if rule.Isotropic {
+				neighbourhood := CalculateNeighbourhood(p, x, y, maxY, immutableWorld)
+				next = rule.NextIsotropic(immutableWorld(y, x), neighbourhood)
+				if rule.Stochastic() {
+					next = rule.Roll(immutableWorld(y, x), next, bits.OnesCount8(neighbourhood), noise.Dice(x, y+offSetY))
 				}
 			} else {
-				if counter == 3 {
-					newWorld[j][x] = live
+				counter := CalculateLiveNeighbour(p, rule.Grid, x, y, maxY, offSetY, immutableWorld)
+				next = rule.Next(immutableWorld(y, x), counter)
+				if rule.Stochastic() {
+					next = rule.Roll(immutableWorld(y, x), next, counter, noise.Dice(x, y+offSetY))
 				}
 			}
-		}
-		//fmt.Printf("For loop y: %v \n", y)
-	}
-	return newWorld
-}
-func CalculateAliveCells(p stubs.Params, startY, endY, offSetY int, immutableWorld func(int, int) byte) []util.Cell {
-	var cells []util.Cell
-	for y := startY; y < endY; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			if immutableWorld(y, x) == live {
+			newWorld[j][x] = next
+			if next == live {
 				cells = append(cells, util.Cell{X: x, Y: y + offSetY})
 			}
 		}
@@ -76,80 +102,144 @@ func CalculateAliveCells(p stubs.Params, startY, endY, offSetY int, immutableWor
 	return cells
 }
 
-// StateWorker worldWorker work on the same board
-// use goroutine to distribute the worker on different section and export via channel
-func StateWorker(p stubs.Params, startY, endY, maxY int, immutableWorld func(int, int) byte, worldCh chan<- [][]byte) {
-	partialWorld := CalculateNextState(p, startY, endY, maxY, immutableWorld)
-	worldCh <- partialWorld
-	//fmt.Println("finish worldCh")
+// rowSums holds the running sums a worker uses for Larger than Life rules. They are kept between turns,
+// so they are only allocated again when the world changes size.
+type rowSums struct {
+	sums    [][]int
+	areas   [][]int
+	extents []int
 }
 
-func AliveCellWorker(p stubs.Params, startY, endY, offSetY int, immutableWorld func(int, int) byte, aliveCellCh chan<- []util.Cell) {
-	partialAliveCells := CalculateAliveCells(p, startY, endY, offSetY, immutableWorld)
-	aliveCellCh <- partialAliveCells
+// resize makes room for the sums of rows padded rows of width cells.
+func (s *rowSums) resize(rows, width int) {
+	if len(s.sums) == rows && len(s.sums[0]) == width {
+		return
+	}
+	s.sums = make([][]int, rows)
+	s.areas = make([][]int, rows+1)
+	for i := range s.areas {
+		s.areas[i] = make([]int, width)
+		if i < rows {
+			s.sums[i] = make([]int, width)
+		}
+	}
 }
 
-// DelegateStateWork accumulate workload for each worker for each turn
-func DelegateStateWork(p stubs.Params, maxY int, immutableWorld func(int, int) byte, worldChs []chan [][]byte, finishWorldCh chan<- [][]byte) {
-	baseWorkload := maxY / p.Threads
-	extraWorkerThreads := maxY % p.Threads
-
-	startY := 0
-	var finishWorld [][]byte
-
-	for t := 0; t < p.Threads; t++ {
-		workload := baseWorkload
-		if t < extraWorkerThreads {
-			workload++
+// calculateRowSums fills in running sums of the live cells along the rows startY-r to endY+r-1 of the world,
+// past its edges as its topology says. Each row is padded with r cells on both sides,
+// so sums[i][k] counts the live cells among the first k cells of padded row i.
+func calculateRowSums(p stubs.Params, r, startY, endY, maxY int, immutableWorld func(int, int) byte, sums [][]int) {
+	for i, row := range sums {
+		y := ((startY-r+i)%maxY + maxY) % maxY
+		for k := 0; k < p.ImageWidth+2*r; k++ {
+			row[k+1] = row[k]
+			if immutableWorld(y, k-r) == live {
+				row[k+1]++
+			}
 		}
-		endY := startY + workload
-		go StateWorker(p, startY, endY, maxY, immutableWorld, worldChs[t])
-		startY = endY
 	}
+}
 
-	for t := 0; t < p.Threads; t++ {
-		finishWorld = append(finishWorld, <-worldChs[t]...)
+// CalculateExtendedNextState is CalculateNextState for Larger than Life rules, whose neighbourhoods reach
+// rule.Range cells away. Counts come from running sums along the rows, so a cell costs one subtraction per row
+// of its neighbourhood instead of one read per neighbour. Moore neighbourhoods also sum the rows up,
+// which brings the cost down to four reads whatever the range.
+func CalculateExtendedNextState(p stubs.Params, rule util.Rule, startY, endY, maxY, offSetY int, immutableWorld func(int, int) byte, newWorld [][]byte, s *rowSums, cells []util.Cell) []util.Cell {
+	r := rule.Range
+	s.resize(endY-startY+2*r, p.ImageWidth+2*r+1)
+	sums := s.sums
+	calculateRowSums(p, r, startY, endY, maxY, immutableWorld, sums)
+
+	var areas [][]int
+	if rule.Neighbourhood == util.Moore {
+		areas = s.areas
+		for i, row := range sums {
+			for k := range row {
+				areas[i+1][k] = areas[i][k] + row[k]
+			}
+		}
 	}
-	finishWorldCh <- finishWorld
-}
 
-func DelegateCellWork(p stubs.Params, maxY, offSetY int, immutableWorld func(int, int) byte, aliveCellChs []chan []util.Cell, finishCellsCh chan<- []util.Cell) {
-	baseWorkload := maxY / p.Threads
-	extraWorkerThreads := maxY % p.Threads
+	if len(s.extents) != 2*r+1 {
+		s.extents = make([]int, 2*r+1)
+	}
+	extents := s.extents
+	for dy := -r; dy <= r; dy++ {
+		extents[dy+r] = rule.RowExtent(dy)
+	}
 
-	startY := 0
+	for y := startY; y < endY; y++ {
+		j := y - startY // adjust the column
+		i := j + r      // row of y in sums
+		for x := 0; x < p.ImageWidth; x++ {
+			k := x + r // column of x in the padded rows
+			counter := 0
+			if areas != nil {
+				counter = areas[i+r+1][k+r+1] - areas[i-r][k+r+1] - areas[i+r+1][k-r] + areas[i-r][k-r]
+			} else {
+				for dy := -r; dy <= r; dy++ {
+					e := extents[dy+r]
+					counter += sums[i+dy][k+e+1] - sums[i+dy][k-e]
+				}
+			}
 
-	var finishCells []util.Cell
+			value := immutableWorld(y, x)
+			if value == live && !rule.Middle {
+				counter--
+			}
+			next := rule.Next(value, counter)
+			newWorld[j][x] = next
+			if next == live {
+				cells = append(cells, util.Cell{X: x, Y: y + offSetY})
+			}
+		}
+	}
+	return cells
+}
 
-	for t := 0; t < p.Threads; t++ {
-		workload := baseWorkload
-		if t < extraWorkerThreads {
-			workload++
+// CalculateAliveCells appends the cells in the live state to cells. Dying cells of Generations rules are not alive.
+func CalculateAliveCells(p stubs.Params, startY, endY, offSetY int, immutableWorld func(int, int) byte, cells []util.Cell) []util.Cell {
+	for y := startY; y < endY; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if immutableWorld(y, x) == live {
+				cells = append(cells, util.Cell{X: x, Y: y + offSetY})
+			}
 		}
-		endY := startY + workload
-		go AliveCellWorker(p, startY, endY, offSetY, immutableWorld, aliveCellChs[t])
-		startY = endY
 	}
+	return cells
+}
 
-	for t := 0; t < p.Threads; t++ {
-		finishCells = append(finishCells, <-aliveCellChs[t]...)
+// makeWorkerWorld is util.MakeImmutableWorld for the rows a worker holds, halo rows included,
+// where the cells past the left and right edges come from the columns the broker sent.
+func makeWorkerWorld(world, left, right [][]byte) func(y, x int) byte {
+	return func(y, x int) byte {
+		if x < 0 {
+			return left[y][len(left[y])+x]
+		}
+		if x >= len(world[y]) {
+			return right[y][x-len(world[y])]
+		}
+		return world[y][x]
 	}
-	finishCellsCh <- finishCells
 }
 
+// HaloRegion holds the rows of the neighbouring workers needed for the next turn,
+// as many as the range of the rule on each side.
 type HaloRegion struct {
-	TopRow    []byte
-	BottomRow []byte
+	TopRows    [][]byte
+	BottomRows [][]byte
 }
 
 type Worker struct {
 	P          stubs.Params
+	Rule       util.Rule
+	Topology   util.Topology
 	World      [][]byte
 	AliveCells []util.Cell
 	StartY     int
 	// Use for sending Halo Regions
-	CurrentTop    []byte
-	CurrentBottom []byte
+	CurrentTop    [][]byte
+	CurrentBottom [][]byte
 	HaloRegion    HaloRegion
 
 	PrevAddr string
@@ -157,20 +247,59 @@ type Worker struct {
 	Workers  int
 	IP       string
 
-	StateChannels          []chan [][]byte
-	AliveCellChannels      []chan []util.Cell
-	ResultStateChannel     chan [][]byte
-	ResultAliveCellChannel chan []util.Cell
+	// Goroutines that step the same strip of the rows every turn, started by Initialise. The rows are
+	// double buffered with room for the halo rows around them: the strips read Buffers[Front] and write
+	// the other buffer, which then becomes the front, so a turn does not allocate another world.
+	Strips       []strip
+	Buffers      [2][][]byte
+	Front        int
+	TurnChannels []chan bool
+	DoneChannel  chan bool
+	// the front buffer for this turn, with the columns the broker sent
+	immutableWorld func(y, x int) byte
+	// random numbers of stochastic rules for this turn
+	noise util.Noise
+}
+
+// strip is the rows one goroutine of a Worker steps, with what it keeps between turns.
+type strip struct {
+	startY, endY int
+	cells        []util.Cell // live cells of the strip after the last turn
+	sums         rowSums     // running sums of Larger than Life rules
+}
+
+// work steps strip t every time it is told to, until its turn channel is closed. It reads the front buffer
+// through w.immutableWorld, which also gives the columns beyond its left and right edges.
+func (w *Worker) work(t int, turnCh <-chan bool, doneCh chan<- bool) {
+	s := &w.Strips[t]
+	halo := w.Rule.Range
+	maxY := len(w.Buffers[0])
+	for range turnCh {
+		back := 1 - w.Front
+		s.cells = CalculateNextState(w.P, w.Rule, w.noise, s.startY, s.endY, maxY, w.StartY-halo, w.immutableWorld,
+			w.Buffers[back][s.startY:s.endY], &s.sums, s.cells[:0])
+		doneCh <- true
+	}
+}
+
+// stopStrips ends the goroutines of the strips from the last Initialise.
+func (w *Worker) stopStrips() {
+	for _, ch := range w.TurnChannels {
+		close(ch)
+	}
+	w.TurnChannels = nil
 }
 
 func (w *Worker) receiveHaloTop(prev string, req stubs.HaloRequest, res *stubs.HaloResponse) {
 	if prev != w.IP {
 		client, _ := rpc.Dial("tcp", prev)
 		_ = client.Call("Worker.SendBottomRegion", req, res)
-		w.HaloRegion.TopRow = res.Top
+		w.HaloRegion.TopRows = res.Top
 	} else {
-		w.HaloRegion.TopRow = make([]byte, len(w.CurrentBottom))
-		copy(w.HaloRegion.TopRow, w.CurrentTop)
+		w.HaloRegion.TopRows = w.CurrentBottom
+	}
+	if w.StartY == 0 {
+		w.HaloRegion.TopRows = w.Topology.EdgeRows(w.HaloRegion.TopRows)
 	}
 }
 
@@ -183,10 +312,12 @@ func (w *Worker) receiveHaloBottom(next string, req stubs.HaloRequest, res *stub
 	if next != w.IP {
 		client, _ := rpc.Dial("tcp", next)
 		_ = client.Call("Worker.SendTopRegion", req, res)
-		w.HaloRegion.BottomRow = res.Bottom
+		w.HaloRegion.BottomRows = res.Bottom
 	} else {
-		w.HaloRegion.BottomRow = make([]byte, len(w.CurrentTop))
-		copy(w.HaloRegion.BottomRow, w.CurrentTop)
+		w.HaloRegion.BottomRows = w.CurrentTop
+	}
+	if w.StartY+len(w.World) == w.P.ImageHeight {
+		w.HaloRegion.BottomRows = w.Topology.EdgeRows(w.HaloRegion.BottomRows)
 	}
 }
 
@@ -206,51 +337,60 @@ func (w *Worker) HaloExchange(req stubs.BrokerRequest, res *stubs.BrokerResponse
 	return
 }
 
-func (w *Worker) addHaloRegion() {
-	var world [][]byte
-	world = append(world, w.HaloRegion.TopRow)
-	world = append(world, w.World...)
-	world = append(world, w.HaloRegion.BottomRow)
-	w.World = world
-}
-
-func (w *Worker) filterHaloRegion() {
-	p := w.P
-	world := util.MakeWorld(p.ImageWidth, len(w.World)-2)
-	for i := range world {
-		world[i] = w.World[i+1]
-	}
-	w.World = world
+func (w *Worker) haloRegionReset() {
+	w.HaloRegion = HaloRegion{TopRows: nil, BottomRows: nil}
 }
 
-func (w *Worker) haloRegionReset() {
-	w.HaloRegion = HaloRegion{TopRow: nil, BottomRow: nil}
+// updateCurrentRegion keeps the rows the neighbouring workers will ask for in the next halo exchange.
+func (w *Worker) updateCurrentRegion() {
+	halo := w.Rule.Range
+	w.CurrentTop = w.World[:halo]
+	w.CurrentBottom = w.World[len(w.World)-halo:]
 }
 
 func (w *Worker) Initialise(req stubs.BrokerRequest, res *stubs.BrokerResponse) (err error) {
 	fmt.Println("initialise")
+	w.stopStrips()
 	w.P = req.P
+	w.Rule = req.Rule
+	w.Topology = req.Topology
 	w.IP = req.IP
-	w.World = req.PartialWorld
 	w.StartY = req.StartY
 
-	w.AliveCellChannels = util.MakeCellWorkerChannels(w.P.Threads)
-	w.StateChannels = util.MakeStateWorkerChannels(w.P.Threads)
-	w.ResultStateChannel = util.MakeNextStateChannel()
-	w.ResultAliveCellChannel = util.MakeNextAliveCellChannel()
+	halo := w.Rule.Range
+	rows := len(req.PartialWorld)
+	w.Front = 0
+	for i := range w.Buffers {
+		w.Buffers[i] = util.MakeWorld(w.P.ImageWidth, rows+2*halo)
+	}
+	for i, row := range req.PartialWorld {
+		copy(w.Buffers[0][i+halo], row)
+	}
+	w.World = w.Buffers[0][halo : halo+rows]
 
-	maxY := len(w.World)
+	baseWorkload := rows / w.P.Threads
+	extraWorkerThreads := rows % w.P.Threads
 
-	immutableWorld := util.MakeImmutableWorld(w.World)
+	w.Strips = make([]strip, w.P.Threads)
+	w.TurnChannels = util.MakeTurnChannels(w.P.Threads)
+	w.DoneChannel = make(chan bool)
+	startY := halo
+	for t := range w.Strips {
+		workload := baseWorkload
+		if t < extraWorkerThreads {
+			workload++
+		}
+		w.Strips[t] = strip{startY: startY, endY: startY + workload}
+		startY += workload
+		go w.work(t, w.TurnChannels[t], w.DoneChannel)
+	}
 
-	go DelegateCellWork(w.P, maxY, w.StartY, immutableWorld, w.AliveCellChannels, w.ResultAliveCellChannel)
-	w.AliveCells = <-w.ResultAliveCellChannel
+	w.AliveCells = CalculateAliveCells(w.P, 0, rows, w.StartY, util.MakeImmutableWorld(w.World), nil)
 
 	w.NextAddr = req.NextAddr
 	w.PrevAddr = req.PrevAddr
 
-	w.CurrentTop = w.World[0]
-	w.CurrentBottom = w.World[len(w.World)-1]
+	w.updateCurrentRegion()
 	w.Workers = req.Workers
 
 	res.PartialWorld = w.World
@@ -262,38 +402,31 @@ func (w *Worker) Initialise(req stubs.BrokerRequest, res *stubs.BrokerResponse)
 
 func (w *Worker) ProcessTurn(req stubs.ProcessRequest, res *stubs.ProcessResponse) (err error) {
 	fmt.Println("Process turn start")
-	if w.Workers == 1 {
-		maxY := len(w.World)
-		fmt.Println(maxY)
-		immutableWorld := util.MakeImmutableWorld(w.World)
-
-		go DelegateStateWork(w.P, maxY, immutableWorld, w.StateChannels, w.ResultStateChannel)
-		w.World = <-w.ResultStateChannel
-
-		go DelegateCellWork(w.P, maxY, w.StartY, immutableWorld, w.AliveCellChannels, w.ResultAliveCellChannel)
-		immutableWorld = util.MakeImmutableWorld(w.World)
-		go DelegateCellWork(w.P, maxY, 0, immutableWorld, w.AliveCellChannels, w.ResultAliveCellChannel)
-		w.AliveCells = <-w.ResultAliveCellChannel
-
-	} else {
-		w.addHaloRegion()
-		maxY := len(w.World)
-		immutableWorld := util.MakeImmutableWorld(w.World)
-
-		go DelegateStateWork(w.P, maxY, immutableWorld, w.StateChannels, w.ResultStateChannel)
-		w.World = <-w.ResultStateChannel
-
-		w.filterHaloRegion()
-		w.haloRegionReset()
+	halo := w.Rule.Range
+	rows := len(w.World)
+	front := w.Buffers[w.Front]
+	for i := 0; i < halo; i++ {
+		copy(front[i], w.HaloRegion.TopRows[i])
+		copy(front[halo+rows+i], w.HaloRegion.BottomRows[i])
+	}
+	w.haloRegionReset()
+	w.immutableWorld = makeWorkerWorld(front, req.Left, req.Right)
+	w.noise = req.Noise
 
-		w.CurrentTop = w.World[0]
-		w.CurrentBottom = w.World[len(w.World)-1]
+	for _, ch := range w.TurnChannels {
+		ch <- true
+	}
+	for range w.Strips {
+		<-w.DoneChannel
+	}
+	w.Front = 1 - w.Front
+	w.World = w.Buffers[w.Front][halo : halo+rows]
 
-		maxY = len(w.World)
-		immutableWorld = util.MakeImmutableWorld(w.World)
+	w.updateCurrentRegion()
 
-		go DelegateCellWork(w.P, maxY, w.StartY, immutableWorld, w.AliveCellChannels, w.ResultAliveCellChannel)
-		w.AliveCells = <-w.ResultAliveCellChannel
+	w.AliveCells = w.AliveCells[:0]
+	for _, s := range w.Strips {
+		w.AliveCells = append(w.AliveCells, s.cells...)
 	}
 
 	res.PartialWorld = w.World
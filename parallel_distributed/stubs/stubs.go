@@ -23,6 +23,19 @@ type Params struct {
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        string // rulestring in B/S notation, e.g. "B36/S23"; empty means B3/S23
+	Topology    string // how the edges of the world are joined, see util.ParseTopology; empty means torus
+	Seed        uint64 // seed of the random numbers of stochastic rules, see util.Noise
+
+	// Files the client starts from and writes its images to.
+	Input     string     // path of a pattern or image to start from, e.g. glider.rle; empty reads images/WxH.pgm
+	Offset    *util.Cell // cell the top left of the input pattern is placed at; nil places it in the middle
+	Output    string     // comma-separated formats images are output in, e.g. "pgm,rle"; empty means pgm
+	OutDir    string     // directory images are output to; empty means gol.DefaultOutDir
+	Plain     bool       // output pbm and pgm images in their plain ASCII forms, P1 and P2
+	Threshold int        // grey level from which input pixels are live cells for two-state rules; 0 means gol.DefaultThreshold
+	Scale     int        // side of the square of pixels each cell is drawn as in png images; 0 means 1
+	Palette   string     // colours of the states in png images, see util.ParsePalette; empty means grey
 }
 
 type GameRequest struct {
@@ -39,6 +52,7 @@ type GameResponse struct {
 	Message    string
 	Kill       bool
 	Paused     bool
+	Origin     util.Cell // universe cell at the top left of World, for unbounded universes
 }
 
 type TickerRequest struct{}
@@ -56,6 +70,8 @@ type BrokerRequest struct {
 	NextAddr     string
 	Workers      int
 	IP           string
+	Rule         util.Rule
+	Topology     util.Topology
 }
 
 type BrokerResponse struct {
@@ -63,7 +79,13 @@ type BrokerResponse struct {
 	PartialAliveCells []util.Cell
 }
 
-type ProcessRequest struct{}
+// ProcessRequest carries the cells left and right of each row of a worker, halo rows included,
+// as many columns wide as the range of the rule, and the random numbers of stochastic rules for the turn.
+type ProcessRequest struct {
+	Left  [][]byte
+	Right [][]byte
+	Noise util.Noise
+}
 
 type ProcessResponse struct {
 	PartialWorld      [][]byte
@@ -90,9 +112,10 @@ type KeyPressResponse struct {
 type HaloRequest struct {
 }
 
+// HaloResponse carries as many rows as the range of the rule.
 type HaloResponse struct {
-	Top    []byte
-	Bottom []byte
+	Top    [][]byte
+	Bottom [][]byte
 }
 
 type CloseRequest struct{}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cubicNeighbours is the number of cubes around a cube.
+const cubicNeighbours = 26
+
+// parseBays reads a 3D rule in the notation of Carter Bays: the fewest and most live neighbours a live cube
+// survives with, then the fewest and most a dead cube is born with, out of the 26 cubes around it.
+// They are written as four digits, e.g. "4555" (Bays' Life 4555) or "5766", or separated by commas
+// when a number has two digits, e.g. "5,7,6,6".
+func parseBays(rulestring string) (Rule, error) {
+	rule := Rule{
+		Birth:   make([]bool, cubicNeighbours+1),
+		Survive: make([]bool, cubicNeighbours+1),
+		States:  2,
+		Range:   1,
+		Cubic:   true,
+	}
+	fields := strings.Split(rulestring, ",")
+	if len(fields) == 1 {
+		fields = strings.Split(rulestring, "")
+	}
+	if len(fields) != 4 {
+		return rule, fmt.Errorf("invalid rule %q: expected four neighbour counts", rulestring)
+	}
+	var counts [4]int
+	for i, field := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 0 || n > cubicNeighbours {
+			return rule, fmt.Errorf("invalid rule %q: neighbour count %q must be between 0 and %v", rulestring, field, cubicNeighbours)
+		}
+		counts[i] = n
+	}
+	for n := counts[0]; n <= counts[1]; n++ {
+		rule.Survive[n] = true
+	}
+	for n := counts[2]; n <= counts[3]; n++ {
+		rule.Birth[n] = true
+	}
+	return rule, nil
+}
+
+// isBays reports whether a rulestring is written in the notation of parseBays.
+func isBays(rulestring string) bool {
+	if strings.Contains(rulestring, ",") {
+		return strings.Count(rulestring, ",") == 3 && strings.Trim(rulestring, "0123456789, ") == ""
+	}
+	return len(rulestring) == 4 && strings.Trim(rulestring, "0123456789") == ""
+}
+
+func (r Rule) writeBays(sb *strings.Builder) {
+	var counts []int
+	for _, included := range [][]bool{r.Survive, r.Birth} {
+		low, high := -1, -1
+		for n, ok := range included {
+			if ok {
+				if low < 0 {
+					low = n
+				}
+				high = n
+			}
+		}
+		if low < 0 {
+			// nothing survives or is born, which no range can say
+			low, high = 1, 0
+		}
+		counts = append(counts, low, high)
+	}
+	separator := ""
+	for _, n := range counts {
+		if n > 9 {
+			separator = ","
+		}
+	}
+	for i, n := range counts {
+		if i > 0 {
+			sb.WriteString(separator)
+		}
+		sb.WriteString(strconv.Itoa(n))
+	}
+}
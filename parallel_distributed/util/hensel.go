@@ -0,0 +1,76 @@
+package util
+
+// A neighbourhood is a bitmask of the live cells among the 8 neighbours of a cell,
+// numbered clockwise from the north-west corner:
+//
+//	0 1 2
+//	7 . 3
+//	6 5 4
+//
+// so that corners are the even bits and edges the odd bits.
+
+// henselLetters gives the letters of Hensel notation for each neighbour count, in canonical order.
+// Counts above 4 use the letters of their complement.
+var henselLetters = [9]string{"", "ce", "cekain", "cekainyqjr", "cekainyqjrtwz", "cekainyqjr", "cekain", "ce", ""}
+
+// henselShapes gives one neighbourhood for each letter of the counts 0 to 4, as the bits of its live neighbours.
+// Every other neighbourhood with that count and letter is a rotation or reflection of it.
+var henselShapes = [5]map[byte][]uint{
+	{0: {}},
+	{'c': {0}, 'e': {1}},
+	{'c': {0, 2}, 'e': {1, 3}, 'k': {0, 5}, 'a': {0, 1}, 'i': {1, 5}, 'n': {0, 4}},
+	{
+		'c': {0, 2, 4}, 'e': {1, 3, 5}, 'k': {0, 3, 5}, 'a': {7, 0, 1}, 'i': {0, 1, 2},
+		'n': {0, 1, 3}, 'y': {0, 2, 5}, 'q': {0, 1, 4}, 'j': {0, 1, 6}, 'r': {0, 1, 5},
+	},
+	{
+		'c': {0, 2, 4, 6}, 'e': {1, 3, 5, 7}, 'k': {0, 1, 3, 6}, 'a': {0, 1, 2, 3}, 'i': {0, 1, 5, 6},
+		'n': {0, 1, 4, 7}, 'y': {0, 1, 4, 6}, 'q': {0, 1, 3, 7}, 'j': {0, 1, 2, 4}, 'r': {0, 1, 3, 5},
+		't': {0, 1, 2, 5}, 'w': {0, 1, 3, 4}, 'z': {0, 1, 4, 5},
+	},
+}
+
+// henselClass holds the letter of each of the 256 neighbourhoods; the count is the number of set bits.
+var henselClass = makeHenselClasses()
+
+func makeHenselClasses() [256]byte {
+	var classes [256]byte
+	for count, shapes := range henselShapes {
+		for letter, bits := range shapes {
+			var neighbourhood uint8
+			for _, b := range bits {
+				neighbourhood |= 1 << b
+			}
+			for _, symmetric := range symmetries(neighbourhood) {
+				classes[symmetric] = letter
+				if count < 4 {
+					classes[^symmetric] = letter
+				}
+			}
+		}
+	}
+	return classes
+}
+
+// symmetries gives the 8 rotations and reflections of a neighbourhood.
+func symmetries(neighbourhood uint8) [8]uint8 {
+	var result [8]uint8
+	for r := 0; r < 4; r++ {
+		var rotated, reflected uint8
+		for b := 0; b < 8; b++ {
+			if neighbourhood&(1<<b) != 0 {
+				rotated |= 1 << ((b + 2*r) % 8)
+				reflected |= 1 << ((8 - (b+2*r)%8) % 8)
+			}
+		}
+		result[2*r] = rotated
+		result[2*r+1] = reflected
+	}
+	return result
+}
+
+// HenselLetter gives the letter of the isotropic configuration a neighbourhood belongs to,
+// or 0 for the single configurations with 0 and 8 live neighbours.
+func HenselLetter(neighbourhood uint8) byte {
+	return henselClass[neighbourhood]
+}
@@ -0,0 +1,289 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The magic numbers of the Netpbm formats ReadNetpbm and WriteNetpbm handle: bitmaps (PBM) and greymaps (PGM),
+// each of them in plain ASCII or raw binary.
+const (
+	PlainPBM = "P1"
+	PlainPGM = "P2"
+	RawPBM   = "P4"
+	RawPGM   = "P5"
+)
+
+// netpbmLineLength is the longest line of the plain formats, which Netpbm asks writers to stay within.
+const netpbmLineLength = 70
+
+// NetpbmHeader is the header of a Netpbm image. Maxval is the grey level of white, which is 1 for bitmaps.
+type NetpbmHeader struct {
+	Magic         string
+	Width, Height int
+	Maxval        int
+}
+
+// netpbmReader reads the tokens of a Netpbm image, skipping the whitespace and the # comments between them.
+type netpbmReader struct {
+	r *bufio.Reader
+}
+
+// skip skips whitespace and comments, which run to the end of their line.
+func (n netpbmReader) skip() error {
+	for {
+		c, err := n.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+		case '#':
+			if _, err := n.r.ReadString('\n'); err != nil {
+				return err
+			}
+		default:
+			return n.r.UnreadByte()
+		}
+	}
+}
+
+// number reads a decimal number, which ends at the first byte that is not a digit.
+func (n netpbmReader) number(name string) (int, error) {
+	if err := n.skip(); err != nil {
+		return 0, fmt.Errorf("missing %v: %v", name, err)
+	}
+	value, digits := 0, 0
+	for {
+		c, err := n.r.ReadByte()
+		if err == io.EOF && digits > 0 {
+			return value, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("missing %v: %v", name, err)
+		}
+		if c < '0' || c > '9' {
+			if digits == 0 {
+				return 0, fmt.Errorf("invalid %v: unexpected %q", name, c)
+			}
+			return value, n.r.UnreadByte()
+		}
+		if value > (1<<31)/10 {
+			return 0, fmt.Errorf("invalid %v: too large", name)
+		}
+		value = 10*value + int(c-'0')
+		digits++
+	}
+}
+
+// header reads the header up to the single whitespace byte before the raster.
+func (n netpbmReader) header() (NetpbmHeader, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(n.r, magic); err != nil {
+		return NetpbmHeader{}, fmt.Errorf("missing magic number: %v", err)
+	}
+	h := NetpbmHeader{Magic: string(magic), Maxval: 1}
+	switch h.Magic {
+	case PlainPBM, PlainPGM, RawPBM, RawPGM:
+	default:
+		return h, fmt.Errorf("not a PBM or PGM image: magic number %q", h.Magic)
+	}
+	var err error
+	if h.Width, err = n.number("width"); err != nil {
+		return h, err
+	}
+	if h.Height, err = n.number("height"); err != nil {
+		return h, err
+	}
+	if h.Width == 0 || h.Height == 0 {
+		return h, fmt.Errorf("invalid size %vx%v", h.Width, h.Height)
+	}
+	if h.Magic == PlainPGM || h.Magic == RawPGM {
+		if h.Maxval, err = n.number("maxval"); err != nil {
+			return h, err
+		}
+		if h.Maxval == 0 || h.Maxval > 65535 {
+			return h, fmt.Errorf("invalid maxval %v, expected 1 to 65535", h.Maxval)
+		}
+	}
+	if h.Magic == RawPBM || h.Magic == RawPGM {
+		// the raster starts after exactly one whitespace byte, as its first bytes may look like whitespace
+		if c, err := n.r.ReadByte(); err != nil {
+			return h, fmt.Errorf("missing raster: %v", err)
+		} else if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '\v' && c != '\f' {
+			return h, fmt.Errorf("expected whitespace before the raster, got %q", c)
+		}
+	}
+	return h, nil
+}
+
+// ReadNetpbmHeader reads the header of a PBM or PGM image, e.g. to find its size.
+func ReadNetpbmHeader(r io.Reader) (NetpbmHeader, error) {
+	return netpbmReader{bufio.NewReader(r)}.header()
+}
+
+// ReadNetpbm reads a PBM or PGM image in its plain (P1, P2) or raw (P4, P5) form, with any comments in its header.
+// It gives the grey level of each pixel from 0 for black to 255 for white, indexed [y][x], scaled from the maxval
+// of the image. Bitmaps mark black pixels with 1, which become 0, and white pixels with 0, which become 255,
+// so a bitmap looks the same as a greymap of the same world.
+func ReadNetpbm(r io.Reader) (NetpbmHeader, [][]byte, error) {
+	n := netpbmReader{bufio.NewReader(r)}
+	h, err := n.header()
+	if err != nil {
+		return h, nil, err
+	}
+	if h.Width > maxImagePixels/h.Height {
+		return h, nil, fmt.Errorf("image of %vx%v pixels is too large", h.Width, h.Height)
+	}
+	world := MakeWorld(h.Width, h.Height)
+	grey := func(value int) byte {
+		return byte((value*255 + h.Maxval/2) / h.Maxval)
+	}
+	ended := func(x, y int, err error) error {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("image ends after %v of %v pixels", y*h.Width+x, h.Width*h.Height)
+		}
+		return err
+	}
+
+	switch h.Magic {
+	case PlainPBM:
+		// the digits of a plain bitmap need no whitespace between them
+		for y, row := range world {
+			for x := range row {
+				if err := n.skip(); err != nil {
+					return h, nil, ended(x, y, err)
+				}
+				c, _ := n.r.ReadByte()
+				if c != '0' && c != '1' {
+					return h, nil, fmt.Errorf("invalid pixel %q at (%v, %v)", c, x, y)
+				}
+				row[x] = grey(int('1' - c))
+			}
+		}
+	case PlainPGM:
+		for y, row := range world {
+			for x := range row {
+				if err := n.skip(); err != nil {
+					return h, nil, ended(x, y, err)
+				}
+				value, err := n.number("pixel")
+				if err != nil {
+					return h, nil, err
+				}
+				if value > h.Maxval {
+					return h, nil, fmt.Errorf("pixel %v at (%v, %v) is above the maxval %v", value, x, y, h.Maxval)
+				}
+				row[x] = grey(value)
+			}
+		}
+	case RawPBM:
+		// each row starts on a new byte, with the leftmost pixel in the highest bit
+		bits := make([]byte, (h.Width+7)/8)
+		for y, row := range world {
+			if read, err := io.ReadFull(n.r, bits); err != nil {
+				return h, nil, ended(8*read, y, err)
+			}
+			for x := range row {
+				row[x] = grey(int(1 - bits[x/8]>>(7-x%8)&1))
+			}
+		}
+	case RawPGM:
+		// greymaps with a maxval above 255 take two bytes a pixel, the most significant first
+		size := 1
+		if h.Maxval > 255 {
+			size = 2
+		}
+		pixels := make([]byte, size*h.Width)
+		for y, row := range world {
+			if read, err := io.ReadFull(n.r, pixels); err != nil {
+				return h, nil, ended(read/size, y, err)
+			}
+			for x := range row {
+				value := int(pixels[x])
+				if size == 2 {
+					value = int(pixels[2*x])<<8 | int(pixels[2*x+1])
+				}
+				if value > h.Maxval {
+					return h, nil, fmt.Errorf("pixel %v at (%v, %v) is above the maxval %v", value, x, y, h.Maxval)
+				}
+				row[x] = grey(value)
+			}
+		}
+	}
+	return h, world, nil
+}
+
+// maxImagePixels bounds the size of the images ReadNetpbm reads and of the patterns ReadRLE and ReadLife106 read,
+// so that a broken header or file cannot use up the memory.
+const maxImagePixels = 1 << 30
+
+// WriteNetpbm writes a world as a Netpbm image of the format of magic, after a # line for each comment.
+// Greymaps keep the value of each cell with a maxval of 255. Bitmaps only have black and white,
+// so only live cells are white and the dying cells of Generations rules are black like dead ones.
+func WriteNetpbm(w io.Writer, world [][]byte, magic string, comments ...string) error {
+	switch magic {
+	case PlainPBM, PlainPGM, RawPBM, RawPGM:
+	default:
+		return fmt.Errorf("cannot write magic number %q", magic)
+	}
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, magic)
+	for _, comment := range comments {
+		fmt.Fprintf(out, "# %v\n", comment)
+	}
+	fmt.Fprintf(out, "%v %v\n", width, height)
+	if magic == PlainPGM || magic == RawPGM {
+		fmt.Fprintln(out, 255)
+	}
+
+	switch magic {
+	case PlainPBM, PlainPGM:
+		for _, row := range world {
+			line := 0
+			for _, value := range row {
+				item, separator := strconv.Itoa(int(value)), " "
+				if magic == PlainPBM {
+					// the digits of a plain bitmap need no whitespace between them
+					item, separator = "1", ""
+					if value == liveValue {
+						item = "0"
+					}
+				}
+				if line == 0 {
+					separator = ""
+				} else if line+len(separator)+len(item) > netpbmLineLength {
+					out.WriteByte('\n')
+					separator, line = "", 0
+				}
+				out.WriteString(separator + item)
+				line += len(separator) + len(item)
+			}
+			out.WriteByte('\n')
+		}
+	case RawPBM:
+		bits := make([]byte, (width+7)/8)
+		for _, row := range world {
+			for i := range bits {
+				bits[i] = 0
+			}
+			for x, value := range row {
+				if value != liveValue {
+					bits[x/8] |= 0x80 >> (x % 8)
+				}
+			}
+			out.Write(bits)
+		}
+	case RawPGM:
+		for _, row := range world {
+			out.Write(row)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pattern is a pattern read from a file, which can be placed into a world of any size.
+// Cells holds the state of each cell, indexed [y][x]: 0 for dead, 1 for live and higher states for the dying
+// states of Generations rules and the states of rule files. Rule is the rulestring the file gives, if any.
+type Pattern struct {
+	Width, Height int
+	Cells         [][]byte
+	Rule          string
+}
+
+// newPattern makes a dead pattern of width x height cells.
+func newPattern(width, height int) Pattern {
+	return Pattern{Width: width, Height: height, Cells: MakeWorld(width, height)}
+}
+
+// LoadPattern reads a pattern file in the format given by its extension: .rle for Run Length Encoded patterns,
+// .cells for plaintext patterns, and .lif or .life for Life 1.06 coordinates.
+func LoadPattern(path string) (Pattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	defer file.Close()
+	var pattern Pattern
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".rle":
+		pattern, err = ReadRLE(file)
+	case ".cells":
+		pattern, err = ReadPlaintext(file)
+	case ".lif", ".life":
+		pattern, err = ReadLife106(file)
+	default:
+		return Pattern{}, fmt.Errorf("cannot read pattern %q: unknown format %q", path, ext)
+	}
+	if err != nil {
+		return pattern, fmt.Errorf("invalid pattern %q: %v", path, err)
+	}
+	return pattern, nil
+}
+
+// InputSize gives the size of the world of an input file without reading all of it: the size in the header of
+// pbm, pgm and pnm images, and the size of patterns, see LoadPattern.
+func InputSize(path string) (width, height int, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pbm", ".pgm", ".pnm":
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer file.Close()
+		header, err := ReadNetpbmHeader(file)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid image %q: %v", path, err)
+		}
+		return header.Width, header.Height, nil
+	}
+	pattern, err := LoadPattern(path)
+	return pattern.Width, pattern.Height, err
+}
+
+// Place puts the pattern into a world, with its top left cell at offset, or in the middle of the world when offset
+// is nil. Cells that fall outside of the world are cut off. The states become the values of the rule,
+// which must have as many states as the pattern uses.
+func (p Pattern) Place(world [][]byte, offset *Cell, rule Rule) error {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	left, top := (width-p.Width)/2, (height-p.Height)/2
+	if offset != nil {
+		left, top = offset.X, offset.Y
+	}
+	for y, row := range p.Cells {
+		for x, state := range row {
+			if int(state) >= rule.States {
+				return fmt.Errorf("the pattern has cells in state %v, but rule %v only has %v states", state, rule, rule.States)
+			}
+			if wx, wy := left+x, top+y; wx >= 0 && wy >= 0 && wx < width && wy < height {
+				world[wy][wx] = rule.Value(int(state))
+			}
+		}
+	}
+	return nil
+}
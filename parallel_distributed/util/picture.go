@@ -0,0 +1,99 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// The named palettes ParsePalette knows.
+const (
+	GreyPalette    = "grey"    // the grey levels of pgm images, from black for dead to white for live cells
+	InversePalette = "inverse" // grey turned around, from white for dead to black for live cells, for printing
+)
+
+// ParsePalette gives the colour of each state of a rule in pictures, indexed by state. The palette is either
+// named, or a comma-separated list of hex colours such as "#ffffff,#000080" for the dead, the live and then
+// the dying states in turn. The dying states left out of a list fade from the live to the dead colour,
+// in the same way as Rule.Value fades them to black. An empty palette is grey.
+func ParsePalette(palette string, rule Rule) (color.Palette, error) {
+	var colours []color.RGBA
+	switch palette {
+	case "", GreyPalette, InversePalette:
+		colours = []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}}
+		if palette == InversePalette {
+			colours[0], colours[1] = colours[1], colours[0]
+		}
+	default:
+		for _, hex := range strings.Split(palette, ",") {
+			var c color.RGBA
+			hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+			if len(hex) != 6 {
+				return nil, fmt.Errorf("invalid colour %q in palette, expected %v, %v or hex colours such as #ff8000",
+					hex, GreyPalette, InversePalette)
+			}
+			if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+				return nil, fmt.Errorf("invalid colour %q in palette: %v", hex, err)
+			}
+			c.A = 255
+			colours = append(colours, c)
+		}
+		if len(colours) < 2 {
+			return nil, fmt.Errorf("palette %q needs a colour for dead and for live cells", palette)
+		}
+	}
+	states := rule.States
+	if states < 2 {
+		states = 2
+	}
+	if len(colours) > states {
+		return nil, fmt.Errorf("palette %q has %v colours, but the rule only has %v states", palette, len(colours), states)
+	}
+
+	dead, live := colours[0], colours[1]
+	fade := func(from, to uint8, state int) uint8 {
+		return uint8(int(from) + (int(to)-int(from))*(state-1)/(states-1))
+	}
+	result := make(color.Palette, states)
+	for state := range result {
+		if state < len(colours) {
+			result[state] = colours[state]
+		} else {
+			result[state] = color.RGBA{fade(live.R, dead.R, state), fade(live.G, dead.G, state), fade(live.B, dead.B, state), 255}
+		}
+	}
+	return result, nil
+}
+
+// Picture draws a world with the colour of the state of each cell, as a square of scale x scale pixels.
+func Picture(world [][]byte, scale int, palette color.Palette, rule Rule) *image.Paletted {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	picture := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), palette)
+	for y, row := range world {
+		for x, value := range row {
+			state := uint8(rule.State(value))
+			if int(state) >= len(palette) {
+				state = uint8(len(palette) - 1)
+			}
+			for dy := 0; dy < scale; dy++ {
+				line := picture.Pix[(y*scale+dy)*picture.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					line[x*scale+dx] = state
+				}
+			}
+		}
+	}
+	return picture
+}
+
+// WritePNG writes a world as a PNG image, see Picture.
+func WritePNG(w io.Writer, world [][]byte, scale int, palette color.Palette, rule Rule) error {
+	return png.Encode(w, Picture(world, scale, palette, rule))
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadPlaintext reads a pattern in the plaintext (.cells) format of LifeWiki:
+//
+//	!Name: Glider
+//	.O.
+//	..O
+//	OOO
+//
+// Lines starting with ! are comments, and every other line is a row of the pattern, where . is dead and O live.
+// Rows may leave out the dead cells at their end. Some files use * for live cells, which is accepted as well.
+func ReadPlaintext(r io.Reader) (Pattern, error) {
+	scanner := bufio.NewScanner(r)
+	var rows []string
+	width := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		if i := strings.IndexFunc(line, func(c rune) bool { return c != '.' && c != 'O' && c != '*' }); i >= 0 {
+			return Pattern{}, fmt.Errorf("unexpected %q in row %v", line[i], len(rows)+1)
+		}
+		rows = append(rows, line)
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Pattern{}, err
+	}
+	pattern := newPattern(width, len(rows))
+	for y, row := range rows {
+		for x, c := range []byte(row) {
+			if c != '.' {
+				pattern.Cells[y][x] = 1
+			}
+		}
+	}
+	return pattern, nil
+}
+
+// WritePlaintext writes the pattern in the plaintext format ReadPlaintext reads, after a ! line for each comment.
+// The format only has dead and live cells, so the cells of the dying states of multi-state patterns are written as dead.
+func (p Pattern) WritePlaintext(w io.Writer, comments ...string) error {
+	out := bufio.NewWriter(w)
+	for _, comment := range comments {
+		fmt.Fprintf(out, "!%v\n", comment)
+	}
+	row := make([]byte, p.Width)
+	for _, states := range p.Cells {
+		// dead cells at the end of a row are left out
+		end := 0
+		for x, state := range states {
+			row[x] = '.'
+			if state == 1 {
+				row[x], end = 'O', x+1
+			}
+		}
+		out.Write(row[:end])
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
@@ -0,0 +1,233 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RuleTree holds the transitions of a rule file as a decision tree, the way Golly runs @TREE rules.
+// Starting from Root, each level of the tree picks a child by the state of the next cell of Offsets,
+// so the next state of a cell costs one lookup per cell of its neighbourhood.
+type RuleTree struct {
+	Offsets []Cell  // cells looked at from the root down, relative to the cell
+	Nodes   []int32 // child of node n for state s at Nodes[n*States+s], below the last level the next state
+	Root    int32
+	StateOf [256]uint8 // state of each value a cell can have in the world, see Rule.State
+	ValueOf []byte     // value of each state, see Rule.Value
+}
+
+// ruleLine is a line of a rule file with its comment and surrounding space removed.
+type ruleLine struct {
+	number int
+	text   string
+}
+
+// ruleSection is the header and the lines of a section of a rule file, e.g. "@TABLE".
+type ruleSection struct {
+	header []string
+	lines  []ruleLine
+}
+
+// LoadRuleFile reads a Golly rule file, whose @TABLE or @TREE section describes a cellular automaton
+// with up to 256 states, see compileTable and parseTree. Cells are stored as the grey levels of their states,
+// as for Generations rules, and only cells in state 1 are alive. The colours of the @COLORS section are kept
+// in Colours for the SDL window.
+func LoadRuleFile(path string) (Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule, err := parseRuleFile(string(data))
+	if err != nil {
+		return rule, fmt.Errorf("invalid rule file %q: %v", path, err)
+	}
+	return rule, nil
+}
+
+func parseRuleFile(text string) (Rule, error) {
+	sections := make(map[string]*ruleSection)
+	var section *ruleSection
+	for i, line := range strings.Split(text, "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '@' {
+			fields := strings.Fields(line)
+			section = &ruleSection{header: fields[1:]}
+			sections[strings.ToUpper(fields[0])] = section
+		} else if section != nil {
+			section.lines = append(section.lines, ruleLine{i + 1, line})
+		}
+	}
+
+	header := sections["@RULE"]
+	if header == nil || len(header.header) == 0 {
+		return Rule{}, fmt.Errorf("expected @RULE followed by the name of the rule")
+	}
+	var tree *RuleTree
+	var states int
+	var err error
+	switch {
+	case sections["@TABLE"] != nil:
+		tree, states, err = compileTable(sections["@TABLE"].lines)
+	case sections["@TREE"] != nil:
+		tree, states, err = parseTree(sections["@TREE"].lines)
+	default:
+		err = fmt.Errorf("expected a @TABLE or @TREE section")
+	}
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rule := Rule{
+		Name:    header.header[0],
+		Birth:   make([]bool, 9),
+		Survive: make([]bool, 9),
+		States:  states,
+		Range:   1,
+		Tree:    tree,
+	}
+	tree.ValueOf = make([]byte, states)
+	for state := range tree.ValueOf {
+		tree.ValueOf[state] = rule.Value(state)
+	}
+	for value := range tree.StateOf {
+		tree.StateOf[value] = uint8(rule.State(byte(value)))
+	}
+
+	if colours := sections["@COLORS"]; colours != nil {
+		rule.Colours, err = parseColours(colours.lines, &rule)
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+	return rule, nil
+}
+
+// parseTree reads the @TREE section of a rule file. After num_states, num_neighbors (4 or 8) and num_nodes,
+// each line is a node: its level and a child for each state. Children of level 1 nodes are next states,
+// the others are the numbers of nodes one level down, counting the lines from 0. The last node is the root,
+// which looks at the cell to the north-west, then north-east, south-west, south-east, north, west, east, south
+// and the cell itself, or north, west, east, south and the cell itself with 4 neighbours.
+func parseTree(lines []ruleLine) (*RuleTree, int, error) {
+	states, neighbours, nodes := 0, 0, -1
+	tree := &RuleTree{}
+	var levels []int
+	for _, line := range lines {
+		if i := strings.IndexByte(line.text, '='); i >= 0 {
+			key := strings.TrimSpace(line.text[:i])
+			value, err := strconv.Atoi(strings.TrimSpace(line.text[i+1:]))
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %v: invalid %v", line.number, key)
+			}
+			switch key {
+			case "num_states":
+				states = value
+			case "num_neighbors":
+				neighbours = value
+			case "num_nodes":
+				nodes = value
+			default:
+				return nil, 0, fmt.Errorf("line %v: unknown setting %q", line.number, key)
+			}
+			continue
+		}
+
+		if states < 2 || states > 256 {
+			return nil, 0, fmt.Errorf("line %v: num_states must be between 2 and 256", line.number)
+		}
+		if neighbours != 4 && neighbours != 8 {
+			return nil, 0, fmt.Errorf("line %v: num_neighbors must be 4 or 8", line.number)
+		}
+		fields := strings.Fields(line.text)
+		if len(fields) != states+1 {
+			return nil, 0, fmt.Errorf("line %v: expected the level of the node and %v children", line.number, states)
+		}
+		level, err := strconv.Atoi(fields[0])
+		if err != nil || level < 1 || level > neighbours+1 {
+			return nil, 0, fmt.Errorf("line %v: invalid level %q", line.number, fields[0])
+		}
+		for _, field := range fields[1:] {
+			child, err := strconv.Atoi(field)
+			if err != nil || child < 0 || level == 1 && child >= states || level > 1 && (child >= len(levels) || levels[child] != level-1) {
+				return nil, 0, fmt.Errorf("line %v: invalid child %q of a level %v node", line.number, field, level)
+			}
+			tree.Nodes = append(tree.Nodes, int32(child))
+		}
+		levels = append(levels, level)
+	}
+
+	if len(levels) == 0 || levels[len(levels)-1] != neighbours+1 {
+		return nil, 0, fmt.Errorf("expected the root of level %v as the last node", neighbours+1)
+	}
+	if nodes >= 0 && nodes != len(levels) {
+		return nil, 0, fmt.Errorf("num_nodes is %v, but there are %v nodes", nodes, len(levels))
+	}
+	tree.Root = int32(len(levels) - 1)
+	if neighbours == 8 {
+		tree.Offsets = []Cell{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: -1}, {X: -1, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {}}
+	} else {
+		tree.Offsets = []Cell{{X: 0, Y: -1}, {X: -1, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {}}
+	}
+	return tree, states, nil
+}
+
+// parseColours reads the @COLORS section of a rule file. A line of four numbers gives a state and its red, green
+// and blue, and a line of six numbers a gradient from the first colour at state 1 to the second at the last state.
+// States without a colour keep the grey of their value.
+func parseColours(lines []ruleLine, rule *Rule) ([][3]byte, error) {
+	colours := make([][3]byte, rule.States)
+	for state := range colours {
+		value := rule.Value(state)
+		colours[state] = [3]byte{value, value, value}
+	}
+	for _, line := range lines {
+		var numbers []int
+		for _, field := range strings.Fields(strings.ReplaceAll(line.text, ",", " ")) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("line %v: invalid number %q", line.number, field)
+			}
+			numbers = append(numbers, n)
+		}
+		switch len(numbers) {
+		case 4:
+			if numbers[0] >= rule.States {
+				return nil, fmt.Errorf("line %v: no state %v", line.number, numbers[0])
+			}
+			colours[numbers[0]] = [3]byte{byte(numbers[1]), byte(numbers[2]), byte(numbers[3])}
+		case 6:
+			last := rule.States - 1
+			for state := 1; state <= last; state++ {
+				for i := range colours[state] {
+					from, to := numbers[i], numbers[i+3]
+					if last > 1 {
+						colours[state][i] = byte(from + (to-from)*(state-1)/(last-1))
+					} else {
+						colours[state][i] = byte(from)
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("line %v: expected a state and a colour, or two colours", line.number)
+		}
+	}
+	return colours, nil
+}
+
+// NextTable gives the value of cell (x, y) in the next turn for rules loaded from a rule file.
+// world is indexed [y][x] and decides what lies beyond the edges, as in util.MakeTopologyWorld.
+func (r *Rule) NextTable(x, y int, world func(int, int) byte) byte {
+	t := r.Tree
+	n := t.Root
+	for _, offset := range t.Offsets {
+		n = t.Nodes[int(n)*r.States+int(t.StateOf[world(y+offset.Y, x+offset.X)])]
+	}
+	return t.ValueOf[n]
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology describes how the edges of the world are joined together.
+type Topology int
+
+const (
+	// Torus joins the left edge to the right and the top to the bottom.
+	Torus Topology = iota
+	// Plane surrounds the world with dead cells.
+	Plane
+	// AliveEdges surrounds the world with live cells.
+	AliveEdges
+	// HorizontalCylinder joins the left edge to the right, the top and bottom are dead.
+	HorizontalCylinder
+	// VerticalCylinder joins the top edge to the bottom, the left and right are dead.
+	VerticalCylinder
+	// KleinBottle joins the left edge to the right, and the top to the bottom with a twist.
+	KleinBottle
+	// CrossSurface joins both pairs of edges with a twist.
+	CrossSurface
+	// Unbounded grows the world as its cells approach the edges, beyond which every cell is dead.
+	Unbounded
+)
+
+var topologyNames = []string{"torus", "plane", "alive", "hcylinder", "vcylinder", "klein", "cross", "unbounded"}
+
+// ParseTopology reads the name of a topology: torus, plane, alive, hcylinder, vcylinder, klein, cross or unbounded.
+// An empty name gives the torus.
+func ParseTopology(name string) (Topology, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Torus, nil
+	}
+	for t, n := range topologyNames {
+		if n == name {
+			return Topology(t), nil
+		}
+	}
+	return Torus, fmt.Errorf("unknown topology %q, expected one of %v", name, strings.Join(topologyNames, ", "))
+}
+
+func (t Topology) String() string {
+	if t < 0 || int(t) >= len(topologyNames) {
+		return "Incorrect Topology"
+	}
+	return topologyNames[t]
+}
+
+// Map gives the cell of a width x height world that (x, y) stands for, for coordinates less than one world size
+// outside of it. ok is false when (x, y) lies beyond a bounded edge, where every cell has the value of Edge.
+// Crossing the top or bottom edge is handled before crossing the left or right edge,
+// which only matters for the corners of a cross-surface.
+func (t Topology) Map(x, y, width, height int) (mx, my int, ok bool) {
+	if y < 0 || y >= height {
+		switch t {
+		case Torus, VerticalCylinder:
+			y = (y + height) % height
+		case KleinBottle, CrossSurface:
+			y = (y + height) % height
+			x = width - 1 - x
+		default:
+			return x, y, false
+		}
+	}
+	if x < 0 || x >= width {
+		switch t {
+		case Torus, HorizontalCylinder, KleinBottle:
+			x = (x + width) % width
+		case CrossSurface:
+			x = (x + width) % width
+			y = height - 1 - y
+		default:
+			return x, y, false
+		}
+	}
+	return x, y, true
+}
+
+// Check reports whether a rule can run on the topology. An unbounded universe cannot run rules
+// where cells with no live neighbours are born, or blocks of dead cells come alive, as the whole universe
+// would come alive at once.
+func (t Topology) Check(rule *Rule) error {
+	if t != Unbounded {
+		return nil
+	}
+	nothing := func(int, int) byte { return deadValue }
+	if (rule.Isotropic && rule.BirthConfigs[0]) || (!rule.Isotropic && rule.Birth[0]) ||
+		(rule.Tree != nil && rule.NextTable(0, 0, nothing) != deadValue) || (rule.Block != nil && rule.Block[0] != 0) {
+		return fmt.Errorf("rule %v gives birth to cells with no live neighbours, which an unbounded universe cannot hold", *rule)
+	}
+	return nil
+}
+
+// Edge gives the value of the cells beyond a bounded edge.
+func (t Topology) Edge() byte {
+	if t == AliveEdges {
+		return liveValue
+	}
+	return deadValue
+}
+
+// MakeTopologyWorld is MakeImmutableWorld for a world with the given topology:
+// the closure also accepts coordinates outside of the world and maps them with Topology.Map.
+func MakeTopologyWorld(world [][]byte, topology Topology) func(y, x int) byte {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	return func(y, x int) byte {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			var ok bool
+			if x, y, ok = topology.Map(x, y, width, height); !ok {
+				return topology.Edge()
+			}
+		}
+		return world[y][x]
+	}
+}
+
+// EdgeRows gives the halo rows beyond the top or bottom edge of the world from the rows at the opposite edge,
+// the way Map joins them.
+func (t Topology) EdgeRows(rows [][]byte) [][]byte {
+	edgeRows := make([][]byte, len(rows))
+	for i, row := range rows {
+		edgeRows[i] = make([]byte, len(row))
+		for x := range row {
+			switch t {
+			case Torus, VerticalCylinder:
+				edgeRows[i][x] = row[x]
+			case KleinBottle, CrossSurface:
+				edgeRows[i][x] = row[len(row)-1-x]
+			default:
+				edgeRows[i][x] = t.Edge()
+			}
+		}
+	}
+	return edgeRows
+}
+
+// EdgeColumns gives the halo columns left and right of the rows startY-halo to endY+halo-1 of the world,
+// halo cells wide, the way Map joins them.
+func (t Topology) EdgeColumns(world [][]byte, startY, endY, halo int) (left, right [][]byte) {
+	width := len(world[0])
+	immutableWorld := MakeTopologyWorld(world, t)
+	for y := startY - halo; y < endY+halo; y++ {
+		leftRow := make([]byte, halo)
+		rightRow := make([]byte, halo)
+		for k := 0; k < halo; k++ {
+			leftRow[k] = immutableWorld(y, k-halo)
+			rightRow[k] = immutableWorld(y, width+k)
+		}
+		left = append(left, leftRow)
+		right = append(right, rightRow)
+	}
+	return left, right
+}
@@ -0,0 +1,97 @@
+package util
+
+import "image"
+
+// An unbounded universe is stored as a world that grows as its cells approach the edges,
+// together with the universe cell at its top left, its origin.
+
+// BoundingBox gives the smallest rectangle of the world holding every cell that is not dead,
+// which is empty when the whole world is dead.
+func BoundingBox(world [][]byte) image.Rectangle {
+	var box image.Rectangle
+	for y, row := range world {
+		for x, value := range row {
+			if value != deadValue {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// nearEdge reports whether a cell that is not dead lies within margin cells of the edges of the world.
+func nearEdge(world [][]byte, margin int) bool {
+	height := len(world)
+	for y, row := range world {
+		width := len(row)
+		if y < margin || y >= height-margin || width <= 2*margin {
+			if !allDead(row) {
+				return true
+			}
+		} else if !allDead(row[:margin]) || !allDead(row[width-margin:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func allDead(cells []byte) bool {
+	for _, value := range cells {
+		if value != deadValue {
+			return false
+		}
+	}
+	return true
+}
+
+// growth gives how many cells to add to a side of the world that is short of room,
+// with half the size of the world spare so that a growing pattern does not grow the world every turn.
+// It is even, so that the rows and columns of hexagonal and triangular grids keep their shape.
+func growth(short, size int) int {
+	if short <= 0 {
+		return 0
+	}
+	return (short + size/2 + 1) &^ 1
+}
+
+// GrowWorld makes room in the world, so that no cell that is not dead lies within margin cells of its edges.
+// It gives the grown world and how many columns and rows were added on its left and top,
+// or the world itself and false when there is enough room already.
+func GrowWorld(world [][]byte, margin int) ([][]byte, Cell, bool) {
+	if !nearEdge(world, margin) {
+		return world, Cell{}, false
+	}
+	height := len(world)
+	width := len(world[0])
+	box := BoundingBox(world)
+
+	left := growth(margin-box.Min.X, width)
+	right := growth(box.Max.X+margin-width, width)
+	top := growth(margin-box.Min.Y, height)
+	bottom := growth(box.Max.Y+margin-height, height)
+
+	grown := MakeWorld(width+left+right, height+top+bottom)
+	for y, row := range world {
+		copy(grown[y+top][left:], row)
+	}
+	return grown, Cell{X: left, Y: top}, true
+}
+
+// CropWorld cuts the world down to its bounding box. It gives the cropped world and the bounding box.
+func CropWorld(world [][]byte) ([][]byte, image.Rectangle) {
+	box := BoundingBox(world)
+	cropped := MakeWorld(box.Dx(), box.Dy())
+	for y := range cropped {
+		copy(cropped[y], world[box.Min.Y+y][box.Min.X:box.Max.X])
+	}
+	return cropped, box
+}
+
+// Translate moves the cells of a world to the universe, whose origin is the universe cell at the top left of the world.
+func Translate(cells []Cell, origin Cell) []Cell {
+	translated := make([]Cell, len(cells))
+	for i, cell := range cells {
+		translated[i] = Cell{X: cell.X + origin.X, Y: cell.Y + origin.Y}
+	}
+	return translated
+}